@@ -0,0 +1,181 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// HistogramConfig buckets of the histograms whose names match the pattern,
+// one of buckets, linear and exponential should be set unless native is set.
+type HistogramConfig struct {
+	Match       string                    `yaml:"match"`       //metric name or glob pattern, such as ServerFilter_*.
+	Buckets     []float64                 `yaml:"buckets"`     //explicit bucket upper bounds.
+	Linear      *LinearBucketsConfig      `yaml:"linear"`      //linear buckets.
+	Exponential *ExponentialBucketsConfig `yaml:"exponential"` //exponential buckets.
+	Native      *NativeHistogramConfig    `yaml:"native"`      //native histogram, overrides the global one.
+}
+
+// NativeHistogramConfig native (sparse) histogram config.
+type NativeHistogramConfig struct {
+	Enable          bool    `yaml:"enable"`          //export native histograms.
+	BucketFactor    float64 `yaml:"bucketfactor"`    //max growth factor between buckets, default 1.1.
+	MaxBucketNumber uint32  `yaml:"maxbucketnumber"` //max number of buckets, default 160.
+	NoClassic       bool    `yaml:"noclassic"`       //do not export classic buckets.
+}
+
+const (
+	defaultNativeBucketFactor    = 1.1
+	defaultNativeMaxBucketNumber = 160
+	// nativeMinResetDuration the histogram is reset when it exceeds the max number of buckets,
+	// but not more often than this.
+	nativeMinResetDuration = time.Hour
+)
+
+// apply sets the native histogram fields of opts.
+func (c NativeHistogramConfig) apply(opts *prometheus.HistogramOpts) {
+	opts.NativeHistogramBucketFactor = c.BucketFactor
+	if opts.NativeHistogramBucketFactor <= 1 {
+		opts.NativeHistogramBucketFactor = defaultNativeBucketFactor
+	}
+	opts.NativeHistogramMaxBucketNumber = c.MaxBucketNumber
+	if opts.NativeHistogramMaxBucketNumber == 0 {
+		opts.NativeHistogramMaxBucketNumber = defaultNativeMaxBucketNumber
+	}
+	opts.NativeHistogramMinResetDuration = nativeMinResetDuration
+	if c.NoClassic {
+		opts.Buckets = nil
+	}
+}
+
+// LinearBucketsConfig count buckets, each width wide, the lowest upper bound is start.
+type LinearBucketsConfig struct {
+	Start float64 `yaml:"start"`
+	Width float64 `yaml:"width"`
+	Count int     `yaml:"count"`
+}
+
+// ExponentialBucketsConfig count buckets, the lowest upper bound is start, each is factor times the previous one.
+type ExponentialBucketsConfig struct {
+	Start  float64 `yaml:"start"`
+	Factor float64 `yaml:"factor"`
+	Count  int     `yaml:"count"`
+}
+
+// bucketRule buckets of the histograms whose names match the pattern.
+type bucketRule struct {
+	pattern string
+	// buckets nil means the buckets are not configured by the rule.
+	buckets []float64
+	native  *NativeHistogramConfig
+}
+
+// match reports whether the metric name matches the pattern.
+func (r bucketRule) match(name string) bool {
+	if r.pattern == name {
+		return true
+	}
+	ok, _ := path.Match(r.pattern, name)
+	return ok
+}
+
+// newBucketRules converts the histogram configs to bucket rules.
+func newBucketRules(cfgs []HistogramConfig) ([]bucketRule, error) {
+	rules := make([]bucketRule, 0, len(cfgs))
+	for _, c := range cfgs {
+		buckets, err := c.buckets()
+		if err != nil {
+			return nil, fmt.Errorf("histogram %s: %w", c.Match, err)
+		}
+		rules = append(rules, bucketRule{pattern: c.Match, buckets: buckets, native: c.Native})
+	}
+	return rules, nil
+}
+
+// buckets returns the bucket upper bounds of the config.
+func (c HistogramConfig) buckets() ([]float64, error) {
+	if _, err := path.Match(c.Match, ""); err != nil || c.Match == "" {
+		return nil, errors.New("invalid match pattern")
+	}
+	switch {
+	case len(c.Buckets) > 0:
+		if err := checkIncreasing(c.Buckets); err != nil {
+			return nil, err
+		}
+		return c.Buckets, nil
+	case c.Linear != nil:
+		if c.Linear.Count < 1 || c.Linear.Width <= 0 {
+			return nil, errors.New("linear buckets need positive count and width")
+		}
+		return prometheus.LinearBuckets(c.Linear.Start, c.Linear.Width, c.Linear.Count), nil
+	case c.Exponential != nil:
+		if c.Exponential.Count < 1 || c.Exponential.Start <= 0 || c.Exponential.Factor <= 1 {
+			return nil, errors.New("exponential buckets need positive count and start, and factor greater than 1")
+		}
+		return prometheus.ExponentialBuckets(c.Exponential.Start, c.Exponential.Factor, c.Exponential.Count), nil
+	case c.Native != nil:
+		return nil, nil
+	default:
+		return nil, errors.New("one of buckets, linear, exponential and native should be set")
+	}
+}
+
+// checkIncreasing checks the bucket upper bounds are in increasing order.
+func checkIncreasing(buckets []float64) error {
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return errors.New("buckets must be in increasing order")
+		}
+	}
+	return nil
+}
+
+// metaBucketLayout key of the bucket layout in metrics.Options.Meta.
+const metaBucketLayout = "prometheus_bucket_layout"
+
+// bucketLayout named buckets of some series of a histogram.
+type bucketLayout struct {
+	name    string
+	buckets []float64
+}
+
+// WithBucketLayout records the histograms of the multi-dimension report with the named buckets instead of
+// the buckets of the metric. Series of all layouts are exported under the same metric name, so the dimensions
+// must tell the series of different layouts apart.
+func WithBucketLayout(name string, buckets []float64) metrics.Option {
+	return func(opts *metrics.Options) {
+		if opts == nil || name == "" || len(buckets) == 0 {
+			return
+		}
+		if opts.Meta == nil {
+			opts.Meta = make(map[string]interface{})
+		}
+		opts.Meta[metaBucketLayout] = &bucketLayout{name: name, buckets: buckets}
+	}
+}
+
+// getBucketLayout returns the bucket layout set by WithBucketLayout.
+func getBucketLayout(opts ...metrics.Option) *bucketLayout {
+	if len(opts) == 0 {
+		return nil
+	}
+	options := &metrics.Options{}
+	for _, o := range opts {
+		o(options)
+	}
+	layout, _ := options.Meta[metaBucketLayout].(*bucketLayout)
+	return layout
+}
+
+// uncheckedCollector hides the descriptors of the collector, so that histograms of the same metric with
+// different buckets can be registered together.
+type uncheckedCollector struct {
+	prometheus.Collector
+}
+
+// Describe describes nothing, which makes the collector unchecked.
+func (uncheckedCollector) Describe(chan<- *prometheus.Desc) {}
@@ -0,0 +1,98 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestHistogramConfig(t *testing.T) {
+	cfg := Config{}.Default()
+	assert.Nil(t, yaml.Unmarshal([]byte(`
+isolatedregistry: true
+histograms:
+  - match: test_buckets_explicit
+    buckets: [1, 2, 3]
+  - match: test_buckets_linear*
+    linear: {start: 10, width: 10, count: 2}
+  - match: test_buckets_*
+    exponential: {start: 1, factor: 10, count: 3}
+`), cfg))
+	s, err := newConfigSink(sinkName, cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{1, 2, 3}, s.histogramBuckets("test_buckets_explicit"))
+	assert.Equal(t, []float64{10, 20}, s.histogramBuckets("test_buckets_linear_a"))
+	assert.Equal(t, []float64{1, 10, 100}, s.histogramBuckets("test_buckets_exponential"))
+	assert.Equal(t, prometheus.DefBuckets, s.histogramBuckets("test_other"))
+	assert.Equal(t, prometheus.DefBuckets, s.timerBuckets("test_other"))
+
+	// rules take precedence over metrics.Histogram.
+	metrics.Histogram("test_buckets_registered", metrics.NewValueBounds(5, 6))
+	assert.Equal(t, []float64{1, 10, 100}, s.histogramBuckets("test_buckets_registered"))
+	metrics.Histogram("test_registered", metrics.NewValueBounds(5, 6))
+	assert.Equal(t, []float64{5, 6}, s.histogramBuckets("test_registered")[:2])
+}
+
+func TestHistogramConfigError(t *testing.T) {
+	for _, c := range []HistogramConfig{
+		{Match: ""},
+		{Match: "[", Buckets: []float64{1}},
+		{Match: "a"},
+		{Match: "a", Buckets: []float64{2, 1}},
+		{Match: "a", Linear: &LinearBucketsConfig{Start: 1, Width: 0, Count: 1}},
+		{Match: "a", Exponential: &ExponentialBucketsConfig{Start: 1, Factor: 1, Count: 1}},
+	} {
+		_, err := newBucketRules([]HistogramConfig{c})
+		assert.NotNil(t, err, c)
+	}
+}
+
+func TestWithHistogramBuckets(t *testing.T) {
+	s := NewSink(WithHistogramBuckets("test_*", []float64{1}), WithBuckets([]float64{2}))
+	assert.Equal(t, []float64{1}, s.histogramBuckets("test_a"))
+	assert.Equal(t, []float64{2}, s.histogramBuckets("other"))
+
+	s = NewSink(WithHistogramBuckets("x_*", []float64{2, 1}), WithHistogramBuckets("[", []float64{1}))
+	assert.Empty(t, s.bucketRules)
+	assert.Nil(t, s.Report(metrics.NewSingleDimensionMetrics("x_a", 1, metrics.PolicyHistogram)))
+}
+
+func TestNativeHistogram(t *testing.T) {
+	cfg := Config{}.Default()
+	assert.Nil(t, yaml.Unmarshal([]byte(`
+isolatedregistry: true
+nativehistogram:
+  enable: true
+histograms:
+  - match: test_native_only
+    native: {enable: true, bucketfactor: 1.5, noclassic: true}
+  - match: test_classic
+    buckets: [1, 2]
+    native: {enable: false}
+`), cfg))
+	s, err := newConfigSink(sinkName, cfg)
+	assert.Nil(t, err)
+	for _, name := range []string{"test_native", "test_native_only", "test_classic"} {
+		_ = s.Report(metrics.NewSingleDimensionMetrics(name, 1, metrics.PolicyHistogram))
+	}
+	mfs, err := s.Gatherer().Gather()
+	assert.Nil(t, err)
+	histograms := make(map[string]*dto.Histogram)
+	for _, mf := range mfs {
+		histograms[mf.GetName()] = mf.GetMetric()[0].GetHistogram()
+	}
+
+	native := histograms["Development_trpc_test_native"]
+	assert.NotNil(t, native.Schema)
+	assert.Len(t, native.GetBucket(), len(prometheus.DefBuckets))
+	nativeOnly := histograms["Development_trpc_test_native_only"]
+	assert.NotNil(t, nativeOnly.Schema)
+	assert.Len(t, nativeOnly.GetBucket(), 0)
+	classic := histograms["Development_trpc_test_classic"]
+	assert.Nil(t, classic.Schema)
+	assert.Len(t, classic.GetBucket(), 2)
+}
@@ -0,0 +1,162 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+// Overflow behaviors when the series of a metric exceed the limit.
+const (
+	// OverflowDrop drops the samples of new series.
+	OverflowDrop = "drop"
+	// OverflowFold records the samples of new series to the series whose label values are all overflowLabelValue.
+	OverflowFold = "fold"
+	// OverflowLog records the samples of new series as usual, only logs new series and counts the samples.
+	OverflowLog = "log"
+)
+
+// overflowLabelValue label value of the series which new series are folded into.
+const overflowLabelValue = "__overflow__"
+
+// layoutOverflowValue label value of the series which new series of the bucket layout are folded into,
+// which tells them from the folded series of the other layouts of the same metric.
+func layoutOverflowValue(layout *bucketLayout) string {
+	return "__overflow_" + layout.name + "__"
+}
+
+// maxRejectedSeries max number of the series beyond the limit tracked of each metric,
+// the others are neither counted nor logged.
+const maxRejectedSeries = 10000
+
+// CardinalityConfig limits the number of series of each metric.
+type CardinalityConfig struct {
+	MaxSeries int            `yaml:"maxseries"` //default series limit of each metric, 0 means unlimited.
+	Overflow  string         `yaml:"overflow"`  //drop, fold or log, drop by default.
+	Limits    map[string]int `yaml:"limits"`    //series limit of specific metrics, 0 means unlimited.
+}
+
+// check checks the overflow behavior.
+func (c *CardinalityConfig) check() error {
+	switch c.Overflow {
+	case "", OverflowDrop, OverflowFold, OverflowLog:
+		return nil
+	default:
+		return fmt.Errorf("unknown overflow %q, should be drop, fold or log", c.Overflow)
+	}
+}
+
+// limit returns the series limit of the metric.
+func (c *CardinalityConfig) limit(name string) int {
+	if l, ok := c.Limits[name]; ok {
+		return l
+	}
+	return c.MaxSeries
+}
+
+// seriesLimiter tracks the series of each metric, and rejects the new ones beyond the limit.
+type seriesLimiter struct {
+	cfg      CardinalityConfig
+	locker   sync.Mutex
+	series   map[string]*seriesSet
+	rejected *prometheus.CounterVec
+}
+
+// seriesSet series of a metric.
+type seriesSet struct {
+	// series series key => whether the series is within the limit.
+	series map[string]bool
+	// within number of series within the limit, the others are the tracked series beyond the limit.
+	within int
+}
+
+func newSeriesLimiter(cfg CardinalityConfig, rejected *prometheus.CounterVec) *seriesLimiter {
+	return &seriesLimiter{
+		cfg:      cfg,
+		series:   make(map[string]*seriesSet),
+		rejected: rejected,
+	}
+}
+
+// newRejectedCounter creates the counter of samples beyond the series limit.
+func newRejectedCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "trpc_metrics_prometheus_series_overflow_total",
+		Help: "Number of distinct series beyond the series limit.",
+	}, []string{"metric"})
+}
+
+// admit returns the label values to record, ok is false if the sample should be dropped.
+// In fold mode, the label values of new series beyond the limit are all overflow.
+func (l *seriesLimiter) admit(name string, values []string, overflow string) (admitted []string, ok bool) {
+	limit := l.cfg.limit(name)
+	if limit <= 0 {
+		return values, true
+	}
+	key := seriesKey(values)
+	l.locker.Lock()
+	set := l.series[name]
+	if set == nil {
+		set = &seriesSet{series: make(map[string]bool)}
+		l.series[name] = set
+	}
+	within, exist := set.series[key]
+	if !exist && set.within < limit {
+		within = true
+		set.series[key] = true
+		set.within++
+	}
+	// track the series beyond the limit to count and log them only once.
+	rejected := !exist && !within && len(set.series)-set.within < maxRejectedSeries
+	if rejected {
+		set.series[key] = false
+	}
+	l.locker.Unlock()
+	if within {
+		return values, true
+	}
+
+	if rejected {
+		l.rejected.WithLabelValues(name).Inc()
+	}
+	switch l.cfg.Overflow {
+	case OverflowFold:
+		folded := make([]string, len(values))
+		for i := range folded {
+			folded[i] = overflow
+		}
+		return folded, true
+	case OverflowLog:
+		if rejected {
+			log.Warnf("trpc-metrics-prometheus:metric %s exceeds series limit %d, labels %v", name, limit, values)
+		}
+		return values, true
+	default:
+		return nil, false
+	}
+}
+
+// forget removes the series, so that a new series can take its place.
+func (l *seriesLimiter) forget(name string, values []string) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	set := l.series[name]
+	if set == nil {
+		return
+	}
+	key := seriesKey(values)
+	if within, ok := set.series[key]; ok {
+		delete(set.series, key)
+		if within {
+			set.within--
+		}
+	}
+}
+
+// seriesKey returns the key of the series of label values.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func reportUsers(s *Sink, name string, users ...string) {
+	for _, u := range users {
+		_ = s.Report(metrics.NewMultiDimensionMetricsX(name,
+			[]*metrics.Dimension{{Name: "user", Value: u}},
+			[]*metrics.Metrics{metrics.NewMetrics("requests", 1, metrics.PolicySUM)}))
+	}
+}
+
+func TestSeriesLimit(t *testing.T) {
+	for _, tt := range []struct {
+		overflow string
+		series   map[string]float64
+	}{
+		{OverflowDrop, map[string]float64{"a": 2, "b": 1}},
+		{OverflowFold, map[string]float64{"a": 2, "b": 1, overflowLabelValue: 3}},
+		{OverflowLog, map[string]float64{"a": 2, "b": 1, "c": 2, "d": 1}},
+	} {
+		t.Run(tt.overflow, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			s := NewSink(WithRegistry(registry), WithCardinality(CardinalityConfig{
+				MaxSeries: 2,
+				Overflow:  tt.overflow,
+				Limits:    map[string]int{"unlimited_requests": 0},
+			}))
+			reportUsers(s, "limited", "a", "b", "c", "a", "d", "c")
+			reportUsers(s, "unlimited", "a", "b", "c", "d")
+
+			limited := s.cache.Loader("countervec_limited_requests", nil).(*prometheus.CounterVec)
+			assert.Equal(t, len(tt.series), testutil.CollectAndCount(limited))
+			for user, v := range tt.series {
+				assert.Equal(t, v, testutil.ToFloat64(limited.WithLabelValues(user)))
+			}
+			unlimited := s.cache.Loader("countervec_unlimited_requests", nil).(*prometheus.CounterVec)
+			assert.Equal(t, 4, testutil.CollectAndCount(unlimited))
+			// distinct series beyond the limit are counted.
+			assert.Equal(t, float64(2), testutil.ToFloat64(s.limiter.rejected.WithLabelValues("limited_requests")))
+		})
+	}
+}
+
+func TestSeriesUnlimited(t *testing.T) {
+	s := NewSink()
+	assert.Nil(t, s.limiter)
+}
+
+func TestCardinalityOverflowInvalid(t *testing.T) {
+	cfg := Config{}.Default()
+	cfg.Cardinality = CardinalityConfig{MaxSeries: 1, Overflow: "folds"}
+	_, err := newConfigSink("test", cfg)
+	assert.NotNil(t, err)
+
+	s := NewSink(WithCardinality(cfg.Cardinality))
+	assert.Nil(t, s.limiter)
+}
@@ -0,0 +1,90 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel/trace"
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// metaExemplar key of the exemplar in metrics.Options.Meta.
+const metaExemplar = "prometheus_exemplar"
+
+// WithExemplar attaches the exemplar to the histogram observations of the report,
+// exemplars are exported in OpenMetrics format. Invalid exemplars are dropped.
+func WithExemplar(exemplar prometheus.Labels) metrics.Option {
+	if err := checkExemplar(exemplar); err != nil {
+		log.Errorf("trpc-metrics-prometheus:exemplar dropped:%v", err)
+		exemplar = nil
+	}
+	return func(opts *metrics.Options) {
+		if opts == nil || len(exemplar) == 0 {
+			return
+		}
+		if opts.Meta == nil {
+			opts.Meta = make(map[string]interface{})
+		}
+		opts.Meta[metaExemplar] = exemplar
+	}
+}
+
+// checkExemplar checks the label names and values of the exemplar, which would panic on observation.
+func checkExemplar(exemplar prometheus.Labels) error {
+	var runes int
+	for name, value := range exemplar {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("label value %q is not valid UTF-8", value)
+		}
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if runes > prometheus.ExemplarMaxRunes {
+		return fmt.Errorf("labels have %d runes, exceeding the limit of %d", runes, prometheus.ExemplarMaxRunes)
+	}
+	return nil
+}
+
+// getExemplar returns the exemplar set by WithExemplar.
+func getExemplar(opts ...metrics.Option) prometheus.Labels {
+	if len(opts) == 0 {
+		return nil
+	}
+	options := &metrics.Options{}
+	for _, o := range opts {
+		o(options)
+	}
+	exemplar, _ := options.Meta[metaExemplar].(prometheus.Labels)
+	return exemplar
+}
+
+// exemplarFromContext returns the exemplar of the trace id and span id of ctx,
+// it returns nil if tracing is not active.
+func exemplarFromContext(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return prometheus.Labels{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+}
+
+// observe observes the value with the exemplar if there is one.
+func observe(o prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if len(exemplar) > 0 {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(value, exemplar)
+			return
+		}
+	}
+	o.Observe(value)
+}
@@ -0,0 +1,73 @@
+package prometheus
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestExemplarFromContext(t *testing.T) {
+	assert.Nil(t, exemplarFromContext(context.Background()))
+
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("0102030405060708")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	assert.Equal(t, prometheus.Labels{
+		"trace_id": "0102030405060708090a0b0c0d0e0f10",
+		"span_id":  "0102030405060708",
+	}, exemplarFromContext(ctx))
+}
+
+func TestOpenMetricsExemplar(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	s := NewSink(WithRegistry(registry))
+	exemplar := WithExemplar(prometheus.Labels{"trace_id": "abc"})
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_exemplar", 1, metrics.PolicyHistogram), exemplar)
+	_ = s.Report(metrics.NewMultiDimensionMetricsX("test_exemplar", []*metrics.Dimension{{Name: "a", Value: "1"}},
+		[]*metrics.Metrics{metrics.NewMetrics("vec", 1, metrics.PolicyHistogram)}), exemplar)
+	// no exemplar.
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_no_exemplar", 1, metrics.PolicyHistogram),
+		WithExemplar(nil))
+
+	cfg := Config{}.Default()
+	assert.False(t, cfg.OpenMetrics)
+	cfg.OpenMetrics = true
+	server, err := newMetricsServer(cfg, registry, registry)
+	assert.Nil(t, err)
+	r := httptest.NewRequest(http.MethodGet, cfg.Path, nil)
+	r.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, r)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/openmetrics-text"))
+	body, err := ioutil.ReadAll(w.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(string(body), `# {trace_id="abc"} 1`))
+}
+
+func TestInvalidExemplar(t *testing.T) {
+	s := NewSink()
+	for _, exemplar := range []prometheus.Labels{
+		{"trace_id": strings.Repeat("a", 200)},
+		{"trace-id": "abc"},
+		{"__trace_id": "abc"},
+		{"trace_id": "\xff\xfe"},
+	} {
+		assert.NotNil(t, checkExemplar(exemplar), exemplar)
+		assert.Nil(t, getExemplar(WithExemplar(exemplar)))
+		assert.NotPanics(t, func() {
+			_ = s.Report(metrics.NewSingleDimensionMetrics("test_invalid_exemplar", 1, metrics.PolicyHistogram),
+				WithExemplar(exemplar))
+		})
+	}
+}
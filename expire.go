@@ -0,0 +1,84 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+)
+
+// seriesExpirer tracks the last update time of series, and deletes the series idle longer than ttl.
+type seriesExpirer struct {
+	ttl    time.Duration
+	delete func(name string, values []string)
+	locker sync.Mutex
+	series map[string]*trackedSeries
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// trackedSeries a series of a multi-dimension metric.
+type trackedSeries struct {
+	name       string
+	values     []string
+	lastUpdate time.Time
+}
+
+func newSeriesExpirer(ttl time.Duration, delete func(name string, values []string)) *seriesExpirer {
+	return &seriesExpirer{
+		ttl:    ttl,
+		delete: delete,
+		series: make(map[string]*trackedSeries),
+		stop:   make(chan struct{}),
+	}
+}
+
+// touch records the update of the series, it should be called before the series is updated,
+// so that the series being updated is never deleted.
+func (e *seriesExpirer) touch(name string, values []string) {
+	key := name + "\xff" + seriesKey(values)
+	now := time.Now()
+	e.locker.Lock()
+	if ts, ok := e.series[key]; ok {
+		ts.lastUpdate = now
+	} else {
+		e.series[key] = &trackedSeries{name: name, values: values, lastUpdate: now}
+	}
+	e.locker.Unlock()
+}
+
+// run sweeps the idle series periodically until it is closed.
+func (e *seriesExpirer) run() {
+	interval := e.ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			e.sweep(now)
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// sweep deletes the series idle longer than ttl.
+func (e *seriesExpirer) sweep(now time.Time) {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	for key, ts := range e.series {
+		if now.Sub(ts.lastUpdate) < e.ttl {
+			continue
+		}
+		// delete under the lock, so that a concurrent update creates a new series after the deletion.
+		e.delete(ts.name, ts.values)
+		delete(e.series, key)
+	}
+}
+
+func (e *seriesExpirer) close() {
+	e.once.Do(func() {
+		close(e.stop)
+	})
+}
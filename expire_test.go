@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestSeriesExpire(t *testing.T) {
+	s := NewSink(WithSeriesTTL(time.Hour), WithCardinality(CardinalityConfig{MaxSeries: 2}))
+	defer s.Close()
+	labels := func(v string) []*metrics.Dimension {
+		return []*metrics.Dimension{{Name: "addr", Value: v}}
+	}
+	report := func(addr string) {
+		_ = s.Report(metrics.NewMultiDimensionMetricsX("test_expire", labels(addr), []*metrics.Metrics{
+			metrics.NewMetrics("requests", 1, metrics.PolicySUM),
+			metrics.NewMetrics("max", 1, metrics.PolicyMAX),
+			metrics.NewMetrics("avg", 1, metrics.PolicyAVG),
+		}))
+	}
+	report("a")
+	report("b")
+	// make a idle.
+	s.expirer.locker.Lock()
+	s.expirer.series["test_expire_requests\xffa"].lastUpdate = time.Now().Add(-2 * time.Hour)
+	s.expirer.locker.Unlock()
+	report("b")
+
+	s.expirer.sweep(time.Now())
+	requests := s.cache.Loader("countervec_test_expire_requests", nil).(*prometheus.CounterVec)
+	assert.Equal(t, 1, testutil.CollectAndCount(requests))
+	assert.Equal(t, float64(2), testutil.ToFloat64(requests.WithLabelValues("b")))
+	avg := s.cache.Loader("avgvec_test_expire_avg", nil).(*prometheus.SummaryVec)
+	assert.Equal(t, 2, testutil.CollectAndCount(avg))
+
+	// the expired series frees its place in the series limit.
+	report("c")
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("c")))
+}
+
+func TestSeriesExpirerRun(t *testing.T) {
+	var deleted []string
+	e := newSeriesExpirer(time.Millisecond, func(name string, values []string) {
+		deleted = append(deleted, name)
+	})
+	e.touch("test", []string{"a"})
+	go e.run()
+	time.Sleep(1500 * time.Millisecond)
+	e.close()
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	assert.Equal(t, []string{"test"}, deleted)
+}
+
+func TestSeriesExpireOtherMetrics(t *testing.T) {
+	s := NewSink(WithSeriesTTL(time.Hour))
+	defer s.Close()
+	labels := []*metrics.Dimension{{Name: "addr", Value: "a"}}
+	_ = s.Report(metrics.NewMultiDimensionMetricsX("test_other", labels, []*metrics.Metrics{
+		metrics.NewMetrics("avg", 1, metrics.PolicyAVG),
+		metrics.NewMetrics("avg_sum", 1, metrics.PolicySUM),
+	}))
+	s.expirer.locker.Lock()
+	s.expirer.series["test_other_avg\xffa"].lastUpdate = time.Now().Add(-2 * time.Hour)
+	s.expirer.locker.Unlock()
+
+	s.expirer.sweep(time.Now())
+	avg := s.cache.Loader("avgvec_test_other_avg", nil).(*prometheus.SummaryVec)
+	assert.Equal(t, 0, testutil.CollectAndCount(avg))
+	// the active metric named like the sum of the expired one is kept.
+	sum := s.cache.Loader("countervec_test_other_avg_sum", nil).(*prometheus.CounterVec)
+	assert.Equal(t, 1, testutil.CollectAndCount(sum))
+}
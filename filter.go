@@ -2,13 +2,11 @@ package prometheus
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"time"
 
 	"trpc.group/trpc-go/trpc-go"
 	"trpc.group/trpc-go/trpc-go/codec"
-	"trpc.group/trpc-go/trpc-go/errs"
 	"trpc.group/trpc-go/trpc-go/filter"
 	"trpc.group/trpc-go/trpc-go/metrics"
 )
@@ -28,74 +26,66 @@ func SetServerBounds(b metrics.BucketBounds) {
 	serverBounds = b
 }
 
-func getLabels(msg codec.Msg, err error) []*metrics.Dimension {
-	code := fmt.Sprintf("%d", errs.RetOK)
-	if err != nil {
-		e, ok := err.(*errs.Error)
-		if ok && e != nil {
-			if e.Desc != "" {
-				code = fmt.Sprintf("%s_%d", e.Desc, e.Code)
-			} else {
-				code = fmt.Sprintf("%d", e.Code)
-			}
-		} else {
-			code = fmt.Sprintf("%d", errs.RetUnknown)
-		}
-	}
-	var remoteAddr, localAddr string
-	if msg.RemoteAddr() != nil {
-		remoteAddr = getAddr(msg.RemoteAddr().String())
-	}
-	if msg.LocalAddr() != nil {
-		localAddr = getAddr(msg.LocalAddr().String())
-	}
-	return []*metrics.Dimension{
-		{Name: "CallerService", Value: msg.CallerService()},
-		{Name: "CallerMethod", Value: msg.CallerMethod()},
-		{Name: "CalleeService", Value: msg.CalleeService()},
-		{Name: "CalleeMethod", Value: msg.CalleeMethod()},
-		{Name: "CalleeContainerName", Value: msg.CalleeContainerName()},
-		{Name: "CalleeSetName", Value: msg.CalleeSetName()},
-		{Name: "RemoteAddr", Value: remoteAddr},
-		{Name: "LocalAddr", Value: localAddr},
-		{Name: "Code", Value: code},
-	}
-}
-
 // ClientFilter client filter for prome.
 func ClientFilter(ctx context.Context, req, rsp interface{}, handler filter.ClientHandleFunc) error {
+	msg := trpc.Message(ctx)
+	if !measured(msg) {
+		return handler(ctx, req, rsp)
+	}
+	defer trackInflight(ctx, "ClientFilter", "rpc_client", clientLabels, msg)()
+	reportDeadline(ctx, clientLabels, msg)
 	begin := time.Now()
 	hErr := handler(ctx, req, rsp)
-	msg := trpc.Message(ctx)
-	labels := getLabels(msg, hErr)
-	ms := make([]*metrics.Metrics, 0)
-	t := float64(time.Since(begin)) / float64(time.Millisecond)
-	ms = append(ms,
-		metrics.NewMetrics("time", t, metrics.PolicyHistogram),
-		metrics.NewMetrics("requests", 1.0, metrics.PolicySUM))
-	metrics.Histogram("ClientFilter_time", clientBounds)
-	r := metrics.NewMultiDimensionMetricsX("ClientFilter", labels, ms)
-	_ = GetDefaultPrometheusSink().Report(r)
+	cost := time.Since(begin)
+	reportCall(ctx, "ClientFilter", "rpc_client", clientBounds, clientLabels, msg, cost, req, rsp, hErr)
 	return hErr
 }
 
 // ServerFilter server filter for prome.
 func ServerFilter(ctx context.Context, req interface{}, handler filter.ServerHandleFunc) (rsp interface{}, err error) {
-	begin := time.Now()
-	rsp, err = handler(ctx, req)
 	msg := trpc.Message(ctx)
-	labels := getLabels(msg, err)
-	ms := make([]*metrics.Metrics, 0)
-	t := float64(time.Since(begin)) / float64(time.Millisecond)
-	ms = append(ms,
-		metrics.NewMetrics("time", t, metrics.PolicyHistogram),
-		metrics.NewMetrics("requests", 1.0, metrics.PolicySUM))
-	metrics.Histogram("ServerFilter_time", serverBounds)
-	r := metrics.NewMultiDimensionMetricsX("ServerFilter", labels, ms)
-	_ = GetDefaultPrometheusSink().Report(r)
+	if !measured(msg) {
+		return handler(ctx, req)
+	}
+	defer trackInflight(ctx, "ServerFilter", "rpc_server", serverLabels, msg)()
+	begin := time.Now()
+	rsp, recovered, err := serve(ctx, req, handler)
+	cost := time.Since(begin)
+	reportCall(ctx, "ServerFilter", "rpc_server", serverBounds, serverLabels, msg, cost, req, rsp, err)
+	if recovered != nil {
+		reportPanic(ctx, msg)
+		if panicMode == PanicRepanic {
+			panic(recovered)
+		}
+	}
 	return rsp, err
 }
 
+// reportCall reports the metrics of the call, legacy is the prefix of the legacy metrics and conventions is the
+// prefix of the ones following the prometheus naming conventions, bounds are the latency buckets in milliseconds.
+// Sampled calls are not reported, and the counters of the reported ones are scaled up.
+func reportCall(ctx context.Context, legacy, conventions string, bounds metrics.BucketBounds, labels *filterLabels,
+	msg codec.Msg, cost time.Duration, req, rsp interface{}, err error) {
+	weight, ok := sample(msg)
+	if !ok {
+		return
+	}
+	dims := labels.dimensions(ctx, msg, err)
+	mb := matchMethodBuckets(msg)
+	exemplar := WithExemplar(exemplarFromContext(ctx))
+	if reportConventions() {
+		ms := []*metrics.Metrics{metrics.NewMetrics("requests_total", weight, metrics.PolicySUM)}
+		ms = appendSizeMetrics(ms, conventions, "_bytes", req, rsp, err)
+		reportLatency(conventions, "duration_seconds", cost.Seconds(), bounds, 1000, mb, dims, ms, exemplar)
+	}
+	if reportLegacy() {
+		ms := []*metrics.Metrics{metrics.NewMetrics("requests", weight, metrics.PolicySUM)}
+		ms = appendSizeMetrics(ms, legacy, "", req, rsp, err)
+		t := float64(cost) / float64(time.Millisecond)
+		reportLatency(legacy, "time", t, bounds, 1, mb, dims, ms, exemplar)
+	}
+}
+
 // getAddr obtains IP, excluding ports.
 func getAddr(add string) string {
 	var addr string
@@ -0,0 +1,156 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// MethodBucketsConfig latency buckets of the methods matching the patterns.
+type MethodBucketsConfig struct {
+	Name    string    `yaml:"name"`    //name of the bucket layout, inserted into the metric names if split.
+	Service string    `yaml:"service"` //callee service or glob pattern, empty matches all.
+	Method  string    `yaml:"method"`  //callee method or glob pattern, empty matches all.
+	Buckets []float64 `yaml:"buckets"` //latency buckets in milliseconds.
+}
+
+var (
+	// methodBuckets latency buckets configured by the plugin, matched before the registered ones.
+	methodBuckets []MethodBucketsConfig
+	// registeredMethodBuckets latency buckets registered by RegisterMethodBuckets.
+	registeredMethodBuckets       []MethodBucketsConfig
+	registeredMethodBucketsLocker sync.RWMutex
+	// splitMethodBuckets reports the latency of the matched methods as separate metrics named by the layout,
+	// such as ServerFilter_batch_time, instead of the same metric with different buckets.
+	splitMethodBuckets bool
+)
+
+// RegisterMethodBuckets registers the latency buckets of the methods matching the patterns,
+// the first matched one is used and the ones configured by the plugin take precedence.
+// The dimensions of the filters are checked against the config applied, or checked again by the plugin Setup.
+func RegisterMethodBuckets(cfg MethodBucketsConfig) error {
+	if err := checkMethodBuckets([]MethodBucketsConfig{cfg}, splitMethodBuckets, serverLabels, clientLabels); err != nil {
+		return err
+	}
+	registeredMethodBucketsLocker.Lock()
+	defer registeredMethodBucketsLocker.Unlock()
+	registeredMethodBuckets = append(registeredMethodBuckets, cfg)
+	return nil
+}
+
+// check validates the config.
+func (c MethodBucketsConfig) check() error {
+	// the name is a part of metric names if split.
+	if !model.IsValidMetricName(model.LabelValue(c.Name)) {
+		return fmt.Errorf("invalid method buckets name %q", c.Name)
+	}
+	if err := checkPatterns(c.Service, c.Method); err != nil {
+		return fmt.Errorf("method buckets %s: %w", c.Name, err)
+	}
+	if len(c.Buckets) == 0 {
+		return fmt.Errorf("method buckets %s: %w", c.Name, errors.New("no buckets"))
+	}
+	if err := checkIncreasing(c.Buckets); err != nil {
+		return fmt.Errorf("method buckets %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// match reports whether the callee of the message matches the patterns.
+func (c MethodBucketsConfig) match(msg codec.Msg) bool {
+	return matchCallee(c.Service, c.Method, msg)
+}
+
+func matchPattern(pattern, name string) bool {
+	if pattern == "" || pattern == name {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// checkMethodBuckets validates the configs of the plugin. Unless split, series of different layouts share
+// the metric, so the dimensions of the filters must include the callee method, and the callee service
+// if any pattern of service is set.
+func checkMethodBuckets(cfgs []MethodBucketsConfig, split bool, labels ...*filterLabels) error {
+	for _, c := range cfgs {
+		if err := c.check(); err != nil {
+			return err
+		}
+	}
+	if split {
+		return nil
+	}
+	registeredMethodBucketsLocker.RLock()
+	all := append(append([]MethodBucketsConfig{}, cfgs...), registeredMethodBuckets...)
+	registeredMethodBucketsLocker.RUnlock()
+	for _, c := range all {
+		for _, l := range labels {
+			if !l.selected["CalleeMethod"] || (c.Service != "" && !l.selected["CalleeService"]) {
+				return fmt.Errorf("method buckets %s: dimensions should include CalleeMethod and CalleeService "+
+					"unless splitmethodbuckets is set", c.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// matchMethodBuckets returns the latency buckets of the callee of the message, nil if no one matches.
+func matchMethodBuckets(msg codec.Msg) *MethodBucketsConfig {
+	for i := range methodBuckets {
+		if methodBuckets[i].match(msg) {
+			return &methodBuckets[i]
+		}
+	}
+	registeredMethodBucketsLocker.RLock()
+	defer registeredMethodBucketsLocker.RUnlock()
+	for i := range registeredMethodBuckets {
+		if registeredMethodBuckets[i].match(msg) {
+			return &registeredMethodBuckets[i]
+		}
+	}
+	return nil
+}
+
+// reportLatency reports the latency histogram named name together with the other metrics of the call.
+// The latency of the methods matching the method buckets is recorded with their buckets, either as a bucket
+// layout of the same metric or as a separate metric if split. Bounds and the method buckets are in
+// milliseconds, they are divided by divisor to match the unit of value.
+func reportLatency(prefix, name string, value float64, bounds metrics.BucketBounds, divisor float64,
+	mb *MethodBucketsConfig, dims []*metrics.Dimension, ms []*metrics.Metrics, opts ...metrics.Option) {
+	sink := GetDefaultPrometheusSink()
+	switch {
+	case mb == nil:
+		metrics.Histogram(prefix+"_"+name, scaleBounds(bounds, divisor))
+		ms = append(ms, metrics.NewMetrics(name, value, metrics.PolicyHistogram))
+	case splitMethodBuckets:
+		name = mb.Name + "_" + name
+		metrics.Histogram(prefix+"_"+name, scaleBounds(mb.Buckets, divisor))
+		ms = append(ms, metrics.NewMetrics(name, value, metrics.PolicyHistogram))
+	default:
+		// the layout applies to all histograms of the record, so the latency is reported alone.
+		metrics.Histogram(prefix+"_"+name, scaleBounds(bounds, divisor))
+		r := metrics.NewMultiDimensionMetricsX(prefix, dims,
+			[]*metrics.Metrics{metrics.NewMetrics(name, value, metrics.PolicyHistogram)})
+		_ = sink.Report(r, append(opts, WithBucketLayout(mb.Name, scaleBounds(mb.Buckets, divisor)))...)
+	}
+	r := metrics.NewMultiDimensionMetricsX(prefix, dims, ms)
+	_ = sink.Report(r, opts...)
+}
+
+// scaleBounds divides the buckets by divisor, such as from milliseconds to seconds.
+func scaleBounds(b metrics.BucketBounds, divisor float64) metrics.BucketBounds {
+	if divisor == 1 {
+		return b
+	}
+	scaled := make(metrics.BucketBounds, len(b))
+	for i, v := range b {
+		scaled[i] = v / divisor
+	}
+	return scaled
+}
@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func callMethods(methods ...string) {
+	for _, method := range methods {
+		ctx, msg := codec.WithNewMessage(context.Background())
+		msg.WithCalleeService("Service")
+		msg.WithCalleeMethod(method)
+		_, _ = ServerFilter(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	}
+}
+
+func TestMethodBuckets(t *testing.T) {
+	_, registry := useTestSink(t)
+	methodBuckets = []MethodBucketsConfig{{Name: "batch", Method: "Batch*", Buckets: []float64{1000, 10000}}}
+	defer func() { methodBuckets = nil }()
+
+	callMethods("BatchExport", "Get")
+	mfs, err := registry.Gather()
+	assert.Nil(t, err)
+	upperBounds := make(map[string]float64)
+	for _, mf := range mfs {
+		if mf.GetName() != "ServerFilter_time" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "CalleeMethod" {
+					upperBounds[l.GetValue()] = m.GetHistogram().GetBucket()[0].GetUpperBound()
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{"BatchExport": 1000, "Get": serverBounds[0]}, upperBounds)
+}
+
+func TestSplitMethodBuckets(t *testing.T) {
+	s, _ := useTestSink(t)
+	methodBuckets = []MethodBucketsConfig{{Name: "batch", Method: "Batch*", Buckets: []float64{1000, 10000}}}
+	splitMethodBuckets = true
+	defer func() { methodBuckets, splitMethodBuckets = nil, false }()
+
+	callMethods("BatchExport", "Get")
+	for _, key := range []string{"histogramvec_ServerFilter_batch_time", "histogramvec_ServerFilter_time"} {
+		v, ok := s.cache.Get(key)
+		assert.True(t, ok)
+		assert.Equal(t, 1, testutil.CollectAndCount(v.(prometheus.Collector)))
+	}
+}
+
+func TestRegisterMethodBuckets(t *testing.T) {
+	defer func() { registeredMethodBuckets = nil }()
+	assert.Nil(t, RegisterMethodBuckets(MethodBucketsConfig{Name: "cache", Service: "*.Cache", Buckets: []float64{0.1, 1}}))
+	assert.NotNil(t, RegisterMethodBuckets(MethodBucketsConfig{Name: "invalid-name", Buckets: []float64{1}}))
+	assert.NotNil(t, RegisterMethodBuckets(MethodBucketsConfig{Name: "pattern", Method: "[", Buckets: []float64{1}}))
+	assert.NotNil(t, RegisterMethodBuckets(MethodBucketsConfig{Name: "empty"}))
+	assert.NotNil(t, RegisterMethodBuckets(MethodBucketsConfig{Name: "order", Buckets: []float64{10, 1}}))
+
+	_, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeService("trpc.app.Cache")
+	assert.Equal(t, "cache", matchMethodBuckets(msg).Name)
+	msg.WithCalleeService("trpc.app.Other")
+	assert.Nil(t, matchMethodBuckets(msg))
+
+	// dimensions without callee service can not tell the layouts apart.
+	labels := mustFilterLabels(FilterLabelsConfig{Labels: []string{"CalleeMethod"}}, codec.Msg.ServerMetaData)
+	assert.NotNil(t, checkMethodBuckets(nil, false, labels))
+	assert.Nil(t, checkMethodBuckets(nil, true, labels))
+
+	// registered after Setup, checked against the applied dimensions.
+	defaultServerLabels := serverLabels
+	serverLabels = mustFilterLabels(FilterLabelsConfig{Labels: []string{"Code"}}, codec.Msg.ServerMetaData)
+	defer func() { serverLabels = defaultServerLabels }()
+	assert.NotNil(t, RegisterMethodBuckets(MethodBucketsConfig{Name: "late", Buckets: []float64{1}}))
+	assert.Len(t, registeredMethodBuckets, 1)
+}
+
+func TestBucketLayoutFold(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	s := NewSink(WithRegistry(registry), WithCardinality(CardinalityConfig{MaxSeries: 1, Overflow: OverflowFold}))
+	report := func(method string, opts ...metrics.Option) {
+		rec := metrics.NewMultiDimensionMetricsX("F", []*metrics.Dimension{{Name: "method", Value: method}},
+			[]*metrics.Metrics{metrics.NewMetrics("time", 1, metrics.PolicyHistogram)})
+		assert.Nil(t, s.Report(rec, opts...))
+	}
+	report("a")
+	report("b")
+	report("c", WithBucketLayout("batch", []float64{1, 2}))
+	mfs, err := registry.Gather()
+	assert.Nil(t, err)
+	var methods []string
+	for _, mf := range mfs {
+		if mf.GetName() == "F_time" {
+			for _, m := range mf.GetMetric() {
+				methods = append(methods, m.GetLabel()[0].GetValue())
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"a", overflowLabelValue, "__overflow_batch__"}, methods)
+}
+
+func TestBucketLayoutDelete(t *testing.T) {
+	s := NewSink(WithRegistry(prometheus.NewRegistry()))
+	rec := metrics.NewMultiDimensionMetricsX("test", []*metrics.Dimension{{Name: "method", Value: "a"}},
+		[]*metrics.Metrics{metrics.NewMetrics("time", 1, metrics.PolicyHistogram)})
+	assert.Nil(t, s.Report(rec, WithBucketLayout("layout", []float64{1, 2})))
+	v, ok := s.cache.Get("histogramvec_test_time\xfflayout")
+	assert.True(t, ok)
+	assert.Equal(t, 1, testutil.CollectAndCount(v.(prometheus.Collector)))
+	s.deleteSeries("test_time", []string{"a"})
+	assert.Equal(t, 0, testutil.CollectAndCount(v.(prometheus.Collector)))
+}
@@ -0,0 +1,144 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"trpc.group/trpc-go/trpc-go/errs"
+)
+
+// Code classes of the CodeClass dimension.
+const (
+	CodeClassSuccess     = "success"
+	CodeClassClientError = "client_error"
+	CodeClassServerError = "server_error"
+	CodeClassTimeout     = "timeout"
+)
+
+// Error types of the ErrorType dimension.
+const (
+	errorTypeSuccess         = "success"
+	errorTypeFramework       = "framework"
+	errorTypeCalleeFramework = "callee_framework"
+	errorTypeBusiness        = "business"
+	errorTypeUnknown         = "unknown"
+)
+
+// CodeClassConfig maps error codes to the classes of the CodeClass dimension.
+type CodeClassConfig struct {
+	// Classes class => codes, overrides the built-in mapping of the codes.
+	Classes map[string][]int `yaml:"classes"`
+	// Default class of the codes which are not mapped, server_error by default.
+	Default string `yaml:"default"`
+}
+
+// codeClasses maps error codes to classes.
+type codeClasses struct {
+	classes  map[int]string
+	fallback string
+}
+
+// builtinCodeClasses built-in classes of the framework codes, the others are the default class.
+var builtinCodeClasses = map[int]string{
+	int(errs.RetOK):                 CodeClassSuccess,
+	int(errs.RetServerDecodeFail):   CodeClassClientError,
+	int(errs.RetServerNoService):    CodeClassClientError,
+	int(errs.RetServerNoFunc):       CodeClassClientError,
+	int(errs.RetServerAuthFail):     CodeClassClientError,
+	int(errs.RetServerValidateFail): CodeClassClientError,
+
+	int(errs.RetServerTimeout):         CodeClassTimeout,
+	int(errs.RetServerFullLinkTimeout): CodeClassTimeout,
+	int(errs.RetClientTimeout):         CodeClassTimeout,
+	int(errs.RetClientFullLinkTimeout): CodeClassTimeout,
+}
+
+// defaultCodeClasses classes used by the filters.
+var defaultCodeClasses = mustCodeClasses(CodeClassConfig{})
+
+// newCodeClasses validates the config and merges it into the built-in mapping.
+func newCodeClasses(cfg CodeClassConfig) (*codeClasses, error) {
+	c := &codeClasses{
+		classes:  make(map[int]string, len(builtinCodeClasses)),
+		fallback: CodeClassServerError,
+	}
+	for code, class := range builtinCodeClasses {
+		c.classes[code] = class
+	}
+	for class, codes := range cfg.Classes {
+		if err := checkCodeClass(class); err != nil {
+			return nil, err
+		}
+		for _, code := range codes {
+			c.classes[code] = class
+		}
+	}
+	if cfg.Default != "" {
+		if err := checkCodeClass(cfg.Default); err != nil {
+			return nil, err
+		}
+		c.fallback = cfg.Default
+	}
+	return c, nil
+}
+
+// checkCodeClass checks the class is one of the code classes.
+func checkCodeClass(class string) error {
+	switch class {
+	case CodeClassSuccess, CodeClassClientError, CodeClassServerError, CodeClassTimeout:
+		return nil
+	default:
+		return fmt.Errorf("unknown code class %q, should be success, client_error, server_error or timeout", class)
+	}
+}
+
+func mustCodeClasses(cfg CodeClassConfig) *codeClasses {
+	c, err := newCodeClasses(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// class returns the class of the error.
+func (c *codeClasses) class(err error) string {
+	code := int(errs.Code(err))
+	if code == int(errs.RetUnknown) && errors.Is(err, context.DeadlineExceeded) {
+		return CodeClassTimeout
+	}
+	if class, ok := c.classes[code]; ok {
+		return class
+	}
+	return c.fallback
+}
+
+// getErrorCode returns the numeric code of the error, wrapped errs.Error is unwrapped.
+func getErrorCode(err error) string {
+	return strconv.Itoa(int(errs.Code(err)))
+}
+
+// getErrorType returns the type of the error.
+func getErrorType(err error) string {
+	if err == nil {
+		return errorTypeSuccess
+	}
+	var e *errs.Error
+	if !errors.As(err, &e) {
+		return errorTypeUnknown
+	}
+	if e == nil {
+		return errorTypeSuccess
+	}
+	switch e.Type {
+	case errs.ErrorTypeFramework:
+		return errorTypeFramework
+	case errs.ErrorTypeCalleeFramework:
+		return errorTypeCalleeFramework
+	case errs.ErrorTypeBusiness:
+		return errorTypeBusiness
+	default:
+		return errorTypeUnknown
+	}
+}
@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/errs"
+)
+
+func TestCodeClass(t *testing.T) {
+	c, err := newCodeClasses(CodeClassConfig{})
+	assert.Nil(t, err)
+	for _, tt := range []struct {
+		err   error
+		class string
+	}{
+		{nil, CodeClassSuccess},
+		{errs.ErrServerTimeout, CodeClassTimeout},
+		{errs.NewFrameError(errs.RetClientTimeout, "timeout"), CodeClassTimeout},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), CodeClassTimeout},
+		{errs.ErrServerNoFunc, CodeClassClientError},
+		{errs.New(10001, "business"), CodeClassServerError},
+		{errors.New("unknown"), CodeClassServerError},
+	} {
+		assert.Equal(t, tt.class, c.class(tt.err), tt.err)
+	}
+
+	c, err = newCodeClasses(CodeClassConfig{
+		Classes: map[string][]int{CodeClassClientError: {10001}, CodeClassServerError: {int(errs.RetServerNoFunc)}},
+		Default: CodeClassClientError,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, CodeClassClientError, c.class(errs.New(10001, "business")))
+	assert.Equal(t, CodeClassServerError, c.class(errs.ErrServerNoFunc))
+	assert.Equal(t, CodeClassClientError, c.class(errs.New(10002, "business")))
+
+	_, err = newCodeClasses(CodeClassConfig{Classes: map[string][]int{"": {1}}})
+	assert.NotNil(t, err)
+	_, err = newCodeClasses(CodeClassConfig{Classes: map[string][]int{"sucess": {1}}})
+	assert.NotNil(t, err)
+	_, err = newCodeClasses(CodeClassConfig{Default: "other"})
+	assert.NotNil(t, err)
+}
+
+func TestErrorLabels(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		code, typ string
+	}{
+		{nil, "0", errorTypeSuccess},
+		{errs.ErrServerTimeout, "21", errorTypeFramework},
+		{&errs.Error{Type: errs.ErrorTypeCalleeFramework, Code: errs.RetClientNetErr}, "141", errorTypeCalleeFramework},
+		{fmt.Errorf("wrapped: %w", errs.New(10001, "business")), "10001", errorTypeBusiness},
+		{errors.New("unknown"), "999", errorTypeUnknown},
+	} {
+		assert.Equal(t, tt.code, getErrorCode(tt.err), tt.err)
+		assert.Equal(t, tt.typ, getErrorType(tt.err), tt.err)
+	}
+}
@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"fmt"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// FilterConfig metrics reported by ServerFilter and ClientFilter.
+type FilterConfig struct {
+	Server FilterLabelsConfig `yaml:"server"` //dimensions of ServerFilter metrics.
+	Client FilterLabelsConfig `yaml:"client"` //dimensions of ClientFilter metrics.
+	Size   FilterSizeConfig   `yaml:"size"`   //request and response size histograms of both filters.
+	// Inflight records the in-flight requests of both filters by callee service and method.
+	Inflight bool `yaml:"inflight"`
+	// CodeClass maps error codes to the classes of the CodeClass dimension.
+	CodeClass CodeClassConfig `yaml:"codeclass"`
+	// Naming legacy, conventions or both, legacy by default.
+	Naming string `yaml:"naming"`
+	// MethodBuckets latency buckets of the methods matching the patterns, the first matched one is used.
+	MethodBuckets []MethodBucketsConfig `yaml:"methodbuckets"`
+	// SplitMethodBuckets reports the latency of the matched methods as separate metrics named by the layout.
+	SplitMethodBuckets bool `yaml:"splitmethodbuckets"`
+	// Include only measures the calls matching the patterns, all calls by default.
+	Include []MethodPattern `yaml:"include"`
+	// Exclude does not measure the calls matching the patterns, such as health checks.
+	Exclude []MethodPattern `yaml:"exclude"`
+	// Sampling sampling ratios of the calls matching the patterns, the first matched one is used.
+	Sampling []SamplingConfig `yaml:"sampling"`
+	// Panic recovers the panic of the handler in ServerFilter and records it, repanic or error,
+	// not recovered by default.
+	Panic string `yaml:"panic"`
+	// Deadline records the remaining deadline of the context at the start of client calls.
+	Deadline DeadlineConfig `yaml:"deadline"`
+}
+
+// filterSettings compiled filter config, which is applied by the default instance.
+type filterSettings struct {
+	server, client     *filterLabels
+	enableSize         bool
+	sizeBounds         metrics.BucketBounds
+	enableInflight     bool
+	codeClasses        *codeClasses
+	naming             string
+	methodBuckets      []MethodBucketsConfig
+	splitMethodBuckets bool
+	include, exclude   []MethodPattern
+	sampling           []SamplingConfig
+	panicMode          string
+	enableDeadline     bool
+	deadlineBounds     metrics.BucketBounds
+}
+
+// newFilterSettings validates and compiles the filter config.
+func newFilterSettings(cfg FilterConfig) (*filterSettings, error) {
+	server, client, err := newFilterConfigLabels(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sizeBounds, err := newSizeBounds(cfg.Size)
+	if err != nil {
+		return nil, fmt.Errorf("size: %w", err)
+	}
+	classes, err := newCodeClasses(cfg.CodeClass)
+	if err != nil {
+		return nil, fmt.Errorf("code class: %w", err)
+	}
+	naming, err := checkNaming(cfg.Naming)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMethodBuckets(cfg.MethodBuckets, cfg.SplitMethodBuckets, server, client); err != nil {
+		return nil, err
+	}
+	if err := checkSampling(cfg.Include, cfg.Exclude, cfg.Sampling); err != nil {
+		return nil, err
+	}
+	if err := checkPanicMode(cfg.Panic); err != nil {
+		return nil, err
+	}
+	deadlineBounds, err := newDeadlineBounds(cfg.Deadline)
+	if err != nil {
+		return nil, fmt.Errorf("deadline: %w", err)
+	}
+	return &filterSettings{
+		server:             server,
+		client:             client,
+		enableSize:         cfg.Size.Enable,
+		sizeBounds:         sizeBounds,
+		enableInflight:     cfg.Inflight,
+		codeClasses:        classes,
+		naming:             naming,
+		methodBuckets:      cfg.MethodBuckets,
+		splitMethodBuckets: cfg.SplitMethodBuckets,
+		include:            cfg.Include,
+		exclude:            cfg.Exclude,
+		sampling:           cfg.Sampling,
+		panicMode:          cfg.Panic,
+		enableDeadline:     cfg.Deadline.Enable,
+		deadlineBounds:     deadlineBounds,
+	}, nil
+}
+
+// apply makes the filters report by the settings.
+func (f *filterSettings) apply() {
+	serverLabels, clientLabels = f.server, f.client
+	enableSize, sizeBounds = f.enableSize, f.sizeBounds
+	enableInflight = f.enableInflight
+	defaultCodeClasses = f.codeClasses
+	filterNaming = f.naming
+	methodBuckets, splitMethodBuckets = f.methodBuckets, f.splitMethodBuckets
+	includeMethods, excludeMethods, samplingRules = f.include, f.exclude, f.sampling
+	panicMode = f.panicMode
+	enableDeadline, deadlineBounds = f.enableDeadline, f.deadlineBounds
+}
+
+// newFilterConfigLabels compiles the dimension sets of both filters.
+func newFilterConfigLabels(cfg FilterConfig) (server, client *filterLabels, err error) {
+	if server, err = newFilterLabels(cfg.Server, codec.Msg.ServerMetaData); err != nil {
+		return nil, nil, fmt.Errorf("server filter labels: %w", err)
+	}
+	if client, err = newFilterLabels(cfg.Client, codec.Msg.ClientMetaData); err != nil {
+		return nil, nil, fmt.Errorf("client filter labels: %w", err)
+	}
+	return server, client, nil
+}
@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// Outcomes of the Outcome dimension.
+const (
+	outcomeSuccess  = "success"
+	outcomeTimeout  = "timeout"
+	outcomeCanceled = "canceled"
+	outcomeError    = "error"
+)
+
+// defaultDeadlineBuckets default buckets of the remaining deadline in milliseconds.
+var defaultDeadlineBuckets = []float64{10, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 30000, 60000}
+
+// DeadlineConfig remaining deadline histogram of ClientFilter.
+type DeadlineConfig struct {
+	// Enable records the remaining deadline of the context at call start, calls without deadline are not recorded.
+	Enable bool `yaml:"enable"`
+	// Buckets buckets in milliseconds, 10ms to 60s by default.
+	Buckets []float64 `yaml:"buckets"`
+}
+
+var (
+	// enableDeadline whether ClientFilter records the remaining deadline.
+	enableDeadline bool
+	// deadlineBounds buckets of the remaining deadline in milliseconds.
+	deadlineBounds = metrics.NewValueBounds(defaultDeadlineBuckets...)
+)
+
+// newDeadlineBounds validates the config and returns the buckets.
+func newDeadlineBounds(cfg DeadlineConfig) (metrics.BucketBounds, error) {
+	if len(cfg.Buckets) == 0 {
+		return metrics.NewValueBounds(defaultDeadlineBuckets...), nil
+	}
+	if err := checkIncreasing(cfg.Buckets); err != nil {
+		return nil, err
+	}
+	return metrics.NewValueBounds(cfg.Buckets...), nil
+}
+
+// getOutcome tells the timeouts and cancellations from the other errors by the error code,
+// the context error of the call is only checked for the errors without code.
+func getOutcome(ctx context.Context, err error) string {
+	if err == nil {
+		return outcomeSuccess
+	}
+	switch errs.Code(err) {
+	case errs.RetClientTimeout, errs.RetClientFullLinkTimeout, errs.RetServerTimeout, errs.RetServerFullLinkTimeout:
+		return outcomeTimeout
+	case errs.RetClientCanceled:
+		return outcomeCanceled
+	case errs.RetUnknown:
+	default:
+		return outcomeError
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return outcomeTimeout
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return outcomeCanceled
+	default:
+		return outcomeError
+	}
+}
+
+// reportDeadline reports the remaining deadline of the context at call start if enabled.
+func reportDeadline(ctx context.Context, labels *filterLabels, msg codec.Msg) {
+	if !enableDeadline {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	dims := labels.openDimensions(ctx, msg)
+	sink := GetDefaultPrometheusSink()
+	if reportConventions() {
+		metrics.Histogram("rpc_client_deadline_remaining_seconds", scaleBounds(deadlineBounds, 1000))
+		_ = sink.Report(metrics.NewMultiDimensionMetricsX("rpc_client", dims, []*metrics.Metrics{
+			metrics.NewMetrics("deadline_remaining_seconds", remaining.Seconds(), metrics.PolicyHistogram)}))
+	}
+	if reportLegacy() {
+		metrics.Histogram("ClientFilter_deadline_remaining", deadlineBounds)
+		t := float64(remaining) / float64(time.Millisecond)
+		_ = sink.Report(metrics.NewMultiDimensionMetricsX("ClientFilter", dims, []*metrics.Metrics{
+			metrics.NewMetrics("deadline_remaining", t, metrics.PolicyHistogram)}))
+	}
+}
@@ -0,0 +1,66 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestOutcome(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	for _, c := range []struct {
+		ctx     context.Context
+		err     error
+		outcome string
+	}{
+		{context.Background(), nil, "success"},
+		{context.Background(), errs.NewFrameError(errs.RetClientTimeout, "timeout"), "timeout"},
+		{context.Background(), errs.NewFrameError(errs.RetClientFullLinkTimeout, "timeout"), "timeout"},
+		{context.Background(), errs.NewFrameError(errs.RetClientCanceled, "canceled"), "canceled"},
+		{context.Background(), context.DeadlineExceeded, "timeout"},
+		{canceled, errors.New("broken pipe"), "canceled"},
+		{context.Background(), errs.New(10001, "business"), "error"},
+		// the callee error is not a timeout even if the context has expired.
+		{expired, errs.New(10001, "business"), "error"},
+		{canceled, errs.New(10001, "business"), "error"},
+	} {
+		assert.Equal(t, c.outcome, getOutcome(c.ctx, c.err))
+	}
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeService("Service")
+	msg.WithCalleeMethod("Method")
+	l := mustFilterLabels(FilterLabelsConfig{Labels: []string{"CalleeMethod", "Outcome"}}, codec.Msg.ClientMetaData)
+	assert.Equal(t, []*metrics.Dimension{{Name: "CalleeMethod", Value: "Method"}, {Name: "Outcome", Value: "timeout"}},
+		l.dimensions(ctx, msg, errs.NewFrameError(errs.RetClientTimeout, "timeout")))
+	assert.Equal(t, []*metrics.Dimension{{Name: "CalleeMethod", Value: "Method"}}, l.openDimensions(ctx, msg))
+}
+
+func TestDeadline(t *testing.T) {
+	_, registry := useTestSink(t)
+	enableDeadline = true
+	defer func() { enableDeadline = false }()
+
+	handler := func(ctx context.Context, req, rsp interface{}) error { return nil }
+	ctx, _ := codec.WithNewMessage(context.Background())
+	assert.Nil(t, ClientFilter(ctx, nil, nil, handler))
+	assert.Equal(t, 0.0, histogramSum(t, registry, "ClientFilter_deadline_remaining"))
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.Nil(t, ClientFilter(ctx, nil, nil, handler))
+	remaining := histogramSum(t, registry, "ClientFilter_deadline_remaining")
+	assert.True(t, remaining > 900 && remaining <= 1000, remaining)
+
+	_, err := newDeadlineBounds(DeadlineConfig{Buckets: []float64{100, 10}})
+	assert.NotNil(t, err)
+}
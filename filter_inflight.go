@@ -0,0 +1,41 @@
+package prometheus
+
+import (
+	"context"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+)
+
+// enableInflight whether the filters record the in-flight requests.
+var enableInflight bool
+
+// inflightLabels built-in dimensions of the in-flight gauges, renamed as the filter config.
+var inflightLabels = []string{"CalleeService", "CalleeMethod"}
+
+// trackInflight increments the in-flight gauges of the filter if enabled, which are named by the naming mode,
+// and returns the function which decrements them after the handler returns.
+func trackInflight(ctx context.Context, legacy, conventions string, l *filterLabels, msg codec.Msg) func() {
+	s := GetDefaultPrometheusSink()
+	if !enableInflight || s == nil {
+		return func() {}
+	}
+	var names []string
+	if reportConventions() {
+		names = append(names, conventions+"_inflight_requests")
+	}
+	if reportLegacy() {
+		names = append(names, legacy+"_inflight")
+	}
+	dims := l.inflightDimensions(ctx, msg)
+	tracked := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := s.addGauge(name, dims, 1); err == nil {
+			tracked = append(tracked, name)
+		}
+	}
+	return func() {
+		for _, name := range tracked {
+			_ = s.addGauge(name, dims, -1)
+		}
+	}
+}
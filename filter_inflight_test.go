@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+)
+
+func TestInflight(t *testing.T) {
+	s, _ := useTestSink(t)
+	enableInflight = true
+	defer func() { enableInflight = false }()
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeService("Service")
+	msg.WithCalleeMethod("Method")
+	inflight := func(name string) float64 {
+		v, ok := s.cache.Get("gaugevec_" + name)
+		assert.True(t, ok)
+		return testutil.ToFloat64(v.(*prometheus.GaugeVec).WithLabelValues("Service", "Method"))
+	}
+
+	_, err := ServerFilter(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		assert.Equal(t, 1.0, inflight("ServerFilter_inflight"))
+		return nil, ClientFilter(ctx, nil, nil, func(ctx context.Context, req, rsp interface{}) error {
+			assert.Equal(t, 1.0, inflight("ClientFilter_inflight"))
+			return nil
+		})
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, inflight("ServerFilter_inflight"))
+	assert.Equal(t, 0.0, inflight("ClientFilter_inflight"))
+}
+
+func TestInflightDisabled(t *testing.T) {
+	s, _ := useTestSink(t)
+
+	ctx, _ := codec.WithNewMessage(context.Background())
+	_, _ = ServerFilter(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	_, ok := s.cache.Get("gaugevec_ServerFilter_inflight")
+	assert.False(t, ok)
+}
+
+func TestInflightConventions(t *testing.T) {
+	s, _ := useTestSink(t)
+	enableInflight, filterNaming = true, NamingConventions
+	defer func() { enableInflight, filterNaming = false, NamingLegacy }()
+
+	ctx, _ := codec.WithNewMessage(context.Background())
+	_, err := ServerFilter(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		v, ok := s.cache.Get("gaugevec_rpc_server_inflight_requests")
+		assert.True(t, ok)
+		assert.Equal(t, 1.0, testutil.ToFloat64(v.(*prometheus.GaugeVec)))
+		return nil, nil
+	})
+	assert.Nil(t, err)
+	_, ok := s.cache.Get("gaugevec_ServerFilter_inflight")
+	assert.False(t, ok)
+}
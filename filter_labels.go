@@ -0,0 +1,375 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/common/model"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// otherLabelValue label value of the values not in the allowlist, beyond the max values or not valid UTF-8.
+const otherLabelValue = "__other__"
+
+// defaultMaxLabelValues default number of distinct values of the labels without allowlist,
+// which are read from trpc metadata or registered extractors.
+const defaultMaxLabelValues = 100
+
+// FilterLabelsConfig selects, renames and extends the built-in dimensions of a filter.
+type FilterLabelsConfig struct {
+	// Labels built-in dimensions or registered label extractors to report in order,
+	// all of the built-in dimensions by default.
+	Labels []string `yaml:"labels"`
+	// Rename dimension in Labels => label name.
+	Rename map[string]string `yaml:"rename"`
+	// Static label name => constant value.
+	Static map[string]string `yaml:"static"`
+	// Metadata label name => trpc metadata (transinfo) key, server metadata for ServerFilter
+	// and client metadata for ClientFilter.
+	Metadata map[string]string `yaml:"metadata"`
+	// Allowlist label name => allowed values, the other non-empty values are reported as __other__.
+	Allowlist map[string][]string `yaml:"allowlist"`
+	// MaxValues number of distinct values of the metadata labels and registered extractors without allowlist,
+	// the values beyond it are reported as __other__, 100 by default, negative means unlimited.
+	MaxValues int `yaml:"maxvalues"`
+}
+
+// LabelExtractor extracts the label value of a call from the context and the message.
+type LabelExtractor func(ctx context.Context, msg codec.Msg) string
+
+var (
+	labelExtractors       = make(map[string]LabelExtractor)
+	labelExtractorsLocker sync.RWMutex
+)
+
+// RegisterLabelExtractor registers a label extractor which can be selected by name in filter labels,
+// it should be called before trpc.NewServer. Built-in dimensions can not be overridden.
+func RegisterLabelExtractor(name string, extractor LabelExtractor) {
+	labelExtractorsLocker.Lock()
+	defer labelExtractorsLocker.Unlock()
+	labelExtractors[name] = extractor
+}
+
+func getLabelExtractor(name string) LabelExtractor {
+	labelExtractorsLocker.RLock()
+	defer labelExtractorsLocker.RUnlock()
+	return labelExtractors[name]
+}
+
+// labelValue gets the label value of a call.
+type labelValue func(ctx context.Context, msg codec.Msg, err error) string
+
+// builtinLabels built-in dimensions of the filters in default order,
+// ErrorCode, ErrorType, CodeClass and Outcome are built-in as well but not reported by default.
+var builtinLabels = []string{
+	"CallerService",
+	"CallerMethod",
+	"CalleeService",
+	"CalleeMethod",
+	"CalleeContainerName",
+	"CalleeSetName",
+	"RemoteAddr",
+	"LocalAddr",
+	"Code",
+}
+
+// codeLabels built-in dimensions of the error, which are unknown until the call ends.
+var codeLabels = map[string]bool{"Code": true, "ErrorCode": true, "ErrorType": true, "CodeClass": true, "Outcome": true}
+
+// builtinLabelValues gets the value of the built-in dimension.
+var builtinLabelValues = map[string]labelValue{
+	"CallerService":       msgLabelValue(codec.Msg.CallerService),
+	"CallerMethod":        msgLabelValue(codec.Msg.CallerMethod),
+	"CalleeService":       msgLabelValue(codec.Msg.CalleeService),
+	"CalleeMethod":        msgLabelValue(codec.Msg.CalleeMethod),
+	"CalleeContainerName": msgLabelValue(codec.Msg.CalleeContainerName),
+	"CalleeSetName":       msgLabelValue(codec.Msg.CalleeSetName),
+	"RemoteAddr": func(_ context.Context, msg codec.Msg, _ error) string {
+		if msg.RemoteAddr() == nil {
+			return ""
+		}
+		return getAddr(msg.RemoteAddr().String())
+	},
+	"LocalAddr": func(_ context.Context, msg codec.Msg, _ error) string {
+		if msg.LocalAddr() == nil {
+			return ""
+		}
+		return getAddr(msg.LocalAddr().String())
+	},
+	"Code":      func(_ context.Context, _ codec.Msg, err error) string { return getCode(err) },
+	"ErrorCode": func(_ context.Context, _ codec.Msg, err error) string { return getErrorCode(err) },
+	"ErrorType": func(_ context.Context, _ codec.Msg, err error) string { return getErrorType(err) },
+	"CodeClass": func(_ context.Context, _ codec.Msg, err error) string { return defaultCodeClasses.class(err) },
+	"Outcome":   func(ctx context.Context, _ codec.Msg, err error) string { return getOutcome(ctx, err) },
+}
+
+func msgLabelValue(field func(codec.Msg) string) labelValue {
+	return func(_ context.Context, msg codec.Msg, _ error) string {
+		return field(msg)
+	}
+}
+
+var (
+	// serverLabels dimensions of ServerFilter metrics.
+	serverLabels = mustFilterLabels(FilterLabelsConfig{}, codec.Msg.ServerMetaData)
+	// clientLabels dimensions of ClientFilter metrics.
+	clientLabels = mustFilterLabels(FilterLabelsConfig{}, codec.Msg.ClientMetaData)
+)
+
+// filterLabels compiled dimension set of a filter.
+type filterLabels struct {
+	// selected the selected built-in dimensions and registered extractors.
+	selected map[string]bool
+	labels   []filterLabel
+	static   []*metrics.Dimension
+	inflight []filterLabel
+}
+
+// filterLabel a dynamic dimension and the label name it is reported as.
+type filterLabel struct {
+	name    string
+	value   labelValue
+	allowed map[string]bool
+	// custom whether it is read from trpc metadata or a registered extractor, whose values are unbounded.
+	custom bool
+	// bound bounds the values of the custom label without allowlist.
+	bound *valueBound
+	// code whether it is a dimension of the error, which is unknown until the call ends.
+	code bool
+}
+
+// newFilterLabels validates the config and compiles the dimension set,
+// metadata returns the metadata the metadata labels are read from.
+func newFilterLabels(cfg FilterLabelsConfig, metadata func(codec.Msg) codec.MetaData) (*filterLabels, error) {
+	selected := cfg.Labels
+	if len(selected) == 0 {
+		selected = builtinLabels
+	}
+	l := &filterLabels{selected: make(map[string]bool, len(selected))}
+	names := make(map[string]bool)
+	addName := func(name string) error {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate label name %q", name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, label := range selected {
+		value, err := selectedLabelValue(label)
+		if err != nil {
+			return nil, err
+		}
+		name := label
+		if renamed, ok := cfg.Rename[label]; ok {
+			name = renamed
+		}
+		if err := addName(name); err != nil {
+			return nil, err
+		}
+		_, builtin := builtinLabelValues[label]
+		l.labels = append(l.labels, filterLabel{name: name, value: value, custom: !builtin, code: codeLabels[label]})
+		l.selected[label] = true
+	}
+	for _, label := range inflightLabels {
+		name := label
+		if renamed, ok := cfg.Rename[label]; ok {
+			name = renamed
+		}
+		l.inflight = append(l.inflight, filterLabel{name: name, value: builtinLabelValues[label]})
+	}
+	for label := range cfg.Rename {
+		if !contains(selected, label) {
+			return nil, fmt.Errorf("rename unselected label %q", label)
+		}
+	}
+	// sort metadata and static labels so that the label schema is stable.
+	for _, name := range sortedKeys(cfg.Metadata) {
+		if err := addName(name); err != nil {
+			return nil, err
+		}
+		key := cfg.Metadata[name]
+		l.labels = append(l.labels, filterLabel{
+			name: name,
+			value: func(_ context.Context, msg codec.Msg, _ error) string {
+				return string(metadata(msg)[key])
+			},
+			custom: true,
+		})
+	}
+	for _, name := range sortedKeys(cfg.Static) {
+		if err := addName(name); err != nil {
+			return nil, err
+		}
+		l.static = append(l.static, &metrics.Dimension{Name: name, Value: cfg.Static[name]})
+	}
+	for name, values := range cfg.Allowlist {
+		i := l.index(name)
+		if i < 0 {
+			return nil, fmt.Errorf("allowlist of unknown label %q", name)
+		}
+		l.labels[i].allowed = make(map[string]bool, len(values))
+		for _, v := range values {
+			l.labels[i].allowed[v] = true
+		}
+	}
+	maxValues := cfg.MaxValues
+	if maxValues == 0 {
+		maxValues = defaultMaxLabelValues
+	}
+	for i := range l.labels {
+		if l.labels[i].custom && l.labels[i].allowed == nil && maxValues > 0 {
+			l.labels[i].bound = newValueBound(maxValues)
+		}
+	}
+	return l, nil
+}
+
+// selectedLabelValue returns the value getter of the built-in dimension or registered extractor.
+func selectedLabelValue(label string) (labelValue, error) {
+	if value, ok := builtinLabelValues[label]; ok {
+		return value, nil
+	}
+	if extractor := getLabelExtractor(label); extractor != nil {
+		return func(ctx context.Context, msg codec.Msg, _ error) string {
+			return extractor(ctx, msg)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown label %q, neither built-in nor registered", label)
+}
+
+// index returns the index of the dynamic label, -1 if not found.
+func (l *filterLabels) index(name string) int {
+	for i := range l.labels {
+		if l.labels[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func mustFilterLabels(cfg FilterLabelsConfig, metadata func(codec.Msg) codec.MetaData) *filterLabels {
+	l, err := newFilterLabels(cfg, metadata)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// dimensions returns the dimensions of the call.
+func (l *filterLabels) dimensions(ctx context.Context, msg codec.Msg, err error) []*metrics.Dimension {
+	return l.collect(ctx, msg, err, true)
+}
+
+// openDimensions returns the dimensions of the call which has not ended, the Code dimension is excluded.
+func (l *filterLabels) openDimensions(ctx context.Context, msg codec.Msg) []*metrics.Dimension {
+	return l.collect(ctx, msg, nil, false)
+}
+
+func (l *filterLabels) collect(ctx context.Context, msg codec.Msg, err error, withCode bool) []*metrics.Dimension {
+	dims := make([]*metrics.Dimension, 0, len(l.labels)+len(l.static))
+	for _, label := range l.labels {
+		if label.code && !withCode {
+			continue
+		}
+		v := label.value(ctx, msg, err)
+		switch {
+		case v == "":
+		case !utf8.ValidString(v):
+			// values from trpc metadata or the request may be any bytes.
+			v = otherLabelValue
+		case label.allowed != nil && !label.allowed[v]:
+			v = otherLabelValue
+		case label.bound != nil:
+			v = label.bound.bound(v)
+		}
+		dims = append(dims, &metrics.Dimension{Name: label.name, Value: v})
+	}
+	return append(dims, l.static...)
+}
+
+// inflightDimensions returns the dimensions of the in-flight gauge of the call.
+func (l *filterLabels) inflightDimensions(ctx context.Context, msg codec.Msg) []*metrics.Dimension {
+	dims := make([]*metrics.Dimension, 0, len(l.inflight)+len(l.static))
+	for _, label := range l.inflight {
+		v := label.value(ctx, msg, nil)
+		if !utf8.ValidString(v) {
+			v = otherLabelValue
+		}
+		dims = append(dims, &metrics.Dimension{Name: label.name, Value: v})
+	}
+	return append(dims, l.static...)
+}
+
+// valueBound keeps the first max distinct values of a label.
+type valueBound struct {
+	max    int
+	locker sync.RWMutex
+	values map[string]bool
+}
+
+func newValueBound(max int) *valueBound {
+	return &valueBound{max: max, values: make(map[string]bool)}
+}
+
+// bound returns the value if it is one of the first max distinct values, otherwise __other__.
+func (b *valueBound) bound(v string) string {
+	b.locker.RLock()
+	ok, full := b.values[v], len(b.values) >= b.max
+	b.locker.RUnlock()
+	if ok {
+		return v
+	}
+	if full {
+		return otherLabelValue
+	}
+	b.locker.Lock()
+	defer b.locker.Unlock()
+	if len(b.values) < b.max {
+		b.values[v] = true
+	}
+	if b.values[v] {
+		return v
+	}
+	return otherLabelValue
+}
+
+// getCode returns the code dimension of the error.
+func getCode(err error) string {
+	if err == nil {
+		return fmt.Sprintf("%d", errs.RetOK)
+	}
+	e, ok := err.(*errs.Error)
+	if !ok || e == nil {
+		return fmt.Sprintf("%d", errs.RetUnknown)
+	}
+	if e.Desc != "" {
+		return fmt.Sprintf("%s_%d", e.Desc, e.Code)
+	}
+	return fmt.Sprintf("%d", e.Code)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestFilterLabels(t *testing.T) {
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCallerService("CallerService")
+	msg.WithCalleeMethod("Method")
+	msg.WithRemoteAddr(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 80})
+
+	l, err := newFilterLabels(FilterLabelsConfig{}, codec.Msg.ServerMetaData)
+	assert.Nil(t, err)
+	dims := l.dimensions(ctx, msg, nil)
+	assert.Len(t, dims, len(builtinLabels))
+	assert.Equal(t, &metrics.Dimension{Name: "RemoteAddr", Value: "127.0.0.1"}, dims[6])
+	assert.Equal(t, &metrics.Dimension{Name: "Code", Value: "0"}, dims[8])
+
+	l, err = newFilterLabels(FilterLabelsConfig{
+		Labels: []string{"CallerService", "CalleeMethod", "Code"},
+		Rename: map[string]string{"CallerService": "caller_service"},
+		Static: map[string]string{"region": "sz", "env": "test"},
+	}, codec.Msg.ServerMetaData)
+	assert.Nil(t, err)
+	assert.Equal(t, []*metrics.Dimension{
+		{Name: "caller_service", Value: "CallerService"},
+		{Name: "CalleeMethod", Value: "Method"},
+		{Name: "Code", Value: "101"},
+		{Name: "env", Value: "test"},
+		{Name: "region", Value: "sz"},
+	}, l.dimensions(ctx, msg, errs.New(101, "timeout")))
+}
+
+type testLabelKey struct{}
+
+func TestLabelExtractor(t *testing.T) {
+	RegisterLabelExtractor("test_from_ctx", func(ctx context.Context, msg codec.Msg) string {
+		v, _ := ctx.Value(testLabelKey{}).(string)
+		return v
+	})
+	ctx, msg := codec.WithNewMessage(context.WithValue(context.Background(), testLabelKey{}, "ctx_value"))
+	msg.WithServerMetaData(codec.MetaData{"x-tenant": []byte("tenant_a")})
+	msg.WithClientMetaData(codec.MetaData{"x-tenant": []byte("tenant_b")})
+
+	cfg := FilterLabelsConfig{
+		Labels:    []string{"Code", "test_from_ctx"},
+		Rename:    map[string]string{"test_from_ctx": "from_ctx"},
+		Metadata:  map[string]string{"tenant": "x-tenant", "env": "x-env"},
+		Allowlist: map[string][]string{"tenant": {"tenant_a"}},
+	}
+	l, err := newFilterLabels(cfg, codec.Msg.ServerMetaData)
+	assert.Nil(t, err)
+	assert.Equal(t, []*metrics.Dimension{
+		{Name: "Code", Value: "0"},
+		{Name: "from_ctx", Value: "ctx_value"},
+		{Name: "env", Value: ""},
+		{Name: "tenant", Value: "tenant_a"},
+	}, l.dimensions(ctx, msg, nil))
+
+	// values beyond the allowlist are folded.
+	l, err = newFilterLabels(cfg, codec.Msg.ClientMetaData)
+	assert.Nil(t, err)
+	assert.Equal(t, otherLabelValue, l.dimensions(ctx, msg, nil)[3].Value)
+}
+
+func TestLabelMaxValues(t *testing.T) {
+	ctx, msg := codec.WithNewMessage(context.Background())
+	tenant := func(l *filterLabels, v string) string {
+		msg.WithServerMetaData(codec.MetaData{"x-tenant": []byte(v)})
+		return l.dimensions(ctx, msg, nil)[1].Value
+	}
+	l, err := newFilterLabels(FilterLabelsConfig{
+		Labels:    []string{"Code"},
+		Metadata:  map[string]string{"tenant": "x-tenant"},
+		MaxValues: 2,
+	}, codec.Msg.ServerMetaData)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", tenant(l, "a"))
+	assert.Equal(t, "b", tenant(l, "b"))
+	assert.Equal(t, otherLabelValue, tenant(l, "c"))
+	assert.Equal(t, "a", tenant(l, "a"))
+	assert.Equal(t, "", tenant(l, ""))
+
+	// bounded by default.
+	l, err = newFilterLabels(FilterLabelsConfig{
+		Labels:   []string{"Code"},
+		Metadata: map[string]string{"tenant": "x-tenant"},
+	}, codec.Msg.ServerMetaData)
+	assert.Nil(t, err)
+	for i := 0; i < defaultMaxLabelValues; i++ {
+		assert.Equal(t, fmt.Sprint(i), tenant(l, fmt.Sprint(i)))
+	}
+	assert.Equal(t, otherLabelValue, tenant(l, "c"))
+
+	// unlimited.
+	l, err = newFilterLabels(FilterLabelsConfig{
+		Labels:    []string{"Code"},
+		Metadata:  map[string]string{"tenant": "x-tenant"},
+		MaxValues: -1,
+	}, codec.Msg.ServerMetaData)
+	assert.Nil(t, err)
+	for i := 0; i <= defaultMaxLabelValues; i++ {
+		assert.Equal(t, fmt.Sprint(i), tenant(l, fmt.Sprint(i)))
+	}
+}
+
+func TestInvalidLabelValue(t *testing.T) {
+	_, registry := useTestSink(t)
+	defaultServerLabels := serverLabels
+	serverLabels = mustFilterLabels(FilterLabelsConfig{
+		Labels:   []string{"CalleeMethod", "Code"},
+		Metadata: map[string]string{"tenant": "x-tenant"},
+	}, codec.Msg.ServerMetaData)
+	defer func() { serverLabels = defaultServerLabels }()
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeMethod("Method")
+	msg.WithServerMetaData(codec.MetaData{"x-tenant": []byte{0xff, 0xfe}})
+	assert.NotPanics(t, func() {
+		_, _ = ServerFilter(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	})
+	mfs, err := registry.Gather()
+	assert.Nil(t, err)
+	var tenants []string
+	for _, mf := range mfs {
+		if mf.GetName() != "ServerFilter_requests" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "tenant" {
+					tenants = append(tenants, l.GetValue())
+				}
+			}
+		}
+	}
+	assert.Equal(t, []string{otherLabelValue}, tenants)
+}
+
+func TestFilterLabelsError(t *testing.T) {
+	for _, cfg := range []FilterLabelsConfig{
+		{Labels: []string{"Unknown"}},
+		{Rename: map[string]string{"Unknown": "unknown"}},
+		{Labels: []string{"Code"}, Rename: map[string]string{"CalleeMethod": "method"}},
+		{Rename: map[string]string{"Code": "invalid-name"}},
+		{Rename: map[string]string{"Code": "CalleeMethod"}},
+		{Static: map[string]string{"Code": "0"}},
+		{Metadata: map[string]string{"Code": "code"}},
+		{Allowlist: map[string][]string{"Unknown": {"a"}}},
+	} {
+		_, err := newFilterLabels(cfg, codec.Msg.ServerMetaData)
+		assert.NotNil(t, err, cfg)
+	}
+	_, _, err := newFilterConfigLabels(FilterConfig{Client: FilterLabelsConfig{Labels: []string{"Unknown"}}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"fmt"
+)
+
+// Naming modes of the metrics of ServerFilter and ClientFilter.
+const (
+	// NamingLegacy reports ServerFilter_time in milliseconds and ServerFilter_requests.
+	NamingLegacy = "legacy"
+	// NamingConventions reports rpc_server_duration_seconds and rpc_server_requests_total
+	// following the prometheus naming conventions.
+	NamingConventions = "conventions"
+	// NamingBoth reports both of them, which is useful during migration.
+	NamingBoth = "both"
+)
+
+// filterNaming naming mode of the filters.
+var filterNaming = NamingLegacy
+
+// checkNaming validates the naming mode, empty means legacy.
+func checkNaming(naming string) (string, error) {
+	switch naming {
+	case "":
+		return NamingLegacy, nil
+	case NamingLegacy, NamingConventions, NamingBoth:
+		return naming, nil
+	default:
+		return "", fmt.Errorf("unknown naming %q, should be legacy, conventions or both", naming)
+	}
+}
+
+// reportLegacy whether the filters report the legacy metrics.
+func reportLegacy() bool {
+	return filterNaming != NamingConventions
+}
+
+// reportConventions whether the filters report the metrics following the prometheus naming conventions.
+func reportConventions() bool {
+	return filterNaming == NamingConventions || filterNaming == NamingBoth
+}
+
+// filterPrefixes returns the prefixes of the filter metrics by the naming mode.
+func filterPrefixes(legacy, conventions string) []string {
+	prefixes := make([]string, 0, 2)
+	if reportConventions() {
+		prefixes = append(prefixes, conventions)
+	}
+	if reportLegacy() {
+		prefixes = append(prefixes, legacy)
+	}
+	return prefixes
+}
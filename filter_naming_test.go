@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestFilterNaming(t *testing.T) {
+	for _, tt := range []struct {
+		naming      string
+		legacy      bool
+		conventions bool
+	}{
+		{NamingLegacy, true, false},
+		{NamingConventions, false, true},
+		{NamingBoth, true, true},
+	} {
+		t.Run(tt.naming, func(t *testing.T) {
+			s, registry := useTestSink(t)
+			filterNaming = tt.naming
+			defer func() { filterNaming = NamingLegacy }()
+
+			ctx, _ := codec.WithNewMessage(context.Background())
+			_, _ = ServerFilter(ctx, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			_ = ClientFilter(ctx, nil, nil, func(ctx context.Context, req, rsp interface{}) error {
+				return nil
+			})
+			for _, key := range []string{"countervec_ServerFilter_requests", "histogramvec_ClientFilter_time"} {
+				_, ok := s.cache.Get(key)
+				assert.Equal(t, tt.legacy, ok, key)
+			}
+			for _, key := range []string{"countervec_rpc_server_requests_total", "histogramvec_rpc_client_duration_seconds"} {
+				_, ok := s.cache.Get(key)
+				assert.Equal(t, tt.conventions, ok, key)
+			}
+			if !tt.conventions {
+				return
+			}
+			mfs, err := registry.Gather()
+			assert.Nil(t, err)
+			var found bool
+			for _, mf := range mfs {
+				if mf.GetName() == "rpc_server_duration_seconds" {
+					found = true
+					h := mf.GetMetric()[0].GetHistogram()
+					assert.Equal(t, serverBounds[0]/1000, h.GetBucket()[0].GetUpperBound())
+					assert.Less(t, h.GetSampleSum(), 1.0)
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestCheckNaming(t *testing.T) {
+	naming, err := checkNaming("")
+	assert.Nil(t, err)
+	assert.Equal(t, NamingLegacy, naming)
+	naming, err = checkNaming(NamingBoth)
+	assert.Nil(t, err)
+	assert.Equal(t, NamingBoth, naming)
+	_, err = checkNaming("unknown")
+	assert.NotNil(t, err)
+	assert.Equal(t, metrics.BucketBounds{0.001, 0.01}, scaleBounds(metrics.NewValueBounds(1, 10), 1000))
+}
@@ -0,0 +1,76 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+	"trpc.group/trpc-go/trpc-go/filter"
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// Panic modes of ServerFilter.
+const (
+	// PanicRepanic recovers the panic of the handler, records it and panics again.
+	PanicRepanic = "repanic"
+	// PanicError recovers the panic of the handler, records it and returns it as an errs error.
+	PanicError = "error"
+)
+
+// panicDesc desc of the error converted from the panic, the Code dimension of the request is panic_31.
+const panicDesc = "panic"
+
+// panicMode panic mode of ServerFilter, empty means the panic is not recovered by the filter.
+var panicMode string
+
+// checkPanicMode validates the panic mode.
+func checkPanicMode(mode string) error {
+	switch mode {
+	case "", PanicRepanic, PanicError:
+		return nil
+	default:
+		return fmt.Errorf("unknown panic mode %q, should be repanic or error", mode)
+	}
+}
+
+// newPanicError converts the recovered value to a framework error.
+func newPanicError(recovered interface{}) error {
+	return &errs.Error{
+		Type: errs.ErrorTypeFramework,
+		Code: errs.RetServerSystemErr,
+		Msg:  fmt.Sprintf("handler panic: %v", recovered),
+		Desc: panicDesc,
+	}
+}
+
+// serve calls the handler, and recovers the panic if the panic mode is set,
+// recovered is the value of the panic and err is converted from it.
+func serve(ctx context.Context, req interface{}, handler filter.ServerHandleFunc) (
+	rsp interface{}, recovered interface{}, err error) {
+	if panicMode == "" {
+		rsp, err = handler(ctx, req)
+		return rsp, nil, err
+	}
+	defer func() {
+		if recovered = recover(); recovered != nil {
+			log.Errorf("trpc-metrics-prometheus:handler panic:%v\n%s", recovered, debug.Stack())
+			rsp, err = nil, newPanicError(recovered)
+		}
+	}()
+	rsp, err = handler(ctx, req)
+	return rsp, nil, err
+}
+
+// reportPanic counts the panic of the handler by callee service and method.
+func reportPanic(ctx context.Context, msg codec.Msg) {
+	dims := serverLabels.inflightDimensions(ctx, msg)
+	sink := GetDefaultPrometheusSink()
+	for _, prefix := range filterPrefixes("ServerFilter", "rpc_server") {
+		r := metrics.NewMultiDimensionMetricsX(prefix, dims,
+			[]*metrics.Metrics{metrics.NewMetrics("panics_total", 1.0, metrics.PolicySUM)})
+		_ = sink.Report(r)
+	}
+}
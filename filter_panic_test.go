@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/errs"
+)
+
+func panicHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	panic("boom")
+}
+
+func TestPanicError(t *testing.T) {
+	s, _ := useTestSink(t)
+	panicMode = PanicError
+	defer func() { panicMode = "" }()
+
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeService("Service")
+	msg.WithCalleeMethod("Method")
+	rsp, err := ServerFilter(ctx, nil, panicHandler)
+	assert.Nil(t, rsp)
+	assert.Equal(t, errs.RetServerSystemErr, errs.Code(err))
+
+	panics, ok := s.cache.Get("countervec_ServerFilter_panics_total")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, testutil.ToFloat64(panics.(*prometheus.CounterVec).WithLabelValues("Service", "Method")))
+	requests, ok := s.cache.Get("countervec_ServerFilter_requests")
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.(*prometheus.CounterVec).WithLabelValues(
+		"", "", "Service", "Method", "", "", "", "", "panic_31")))
+}
+
+func TestPanicRepanic(t *testing.T) {
+	s, _ := useTestSink(t)
+	panicMode = PanicRepanic
+	filterNaming = NamingConventions
+	defer func() { panicMode, filterNaming = "", NamingLegacy }()
+
+	ctx, _ := codec.WithNewMessage(context.Background())
+	assert.PanicsWithValue(t, "boom", func() { _, _ = ServerFilter(ctx, nil, panicHandler) })
+	panics, ok := s.cache.Get("countervec_rpc_server_panics_total")
+	assert.True(t, ok)
+	assert.Equal(t, 1, testutil.CollectAndCount(panics.(prometheus.Collector)))
+}
+
+func TestPanicNotRecovered(t *testing.T) {
+	s, _ := useTestSink(t)
+	ctx, _ := codec.WithNewMessage(context.Background())
+	assert.Panics(t, func() { _, _ = ServerFilter(ctx, nil, panicHandler) })
+	_, ok := s.cache.Get("countervec_ServerFilter_panics_total")
+	assert.False(t, ok)
+
+	assert.Nil(t, checkPanicMode(PanicError))
+	assert.NotNil(t, checkPanicMode("unknown"))
+}
@@ -0,0 +1,93 @@
+package prometheus
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+
+	"trpc.group/trpc-go/trpc-go/codec"
+)
+
+// MethodPattern callee service and method patterns of the calls.
+type MethodPattern struct {
+	Service string `yaml:"service"` //callee service or glob pattern, empty matches all.
+	Method  string `yaml:"method"`  //callee method or glob pattern, empty matches all.
+}
+
+// SamplingConfig sampling ratio of the calls matching the patterns.
+type SamplingConfig struct {
+	Service string  `yaml:"service"` //callee service or glob pattern, empty matches all.
+	Method  string  `yaml:"method"`  //callee method or glob pattern, empty matches all.
+	Ratio   float64 `yaml:"ratio"`   //ratio of the calls to report in (0, 1], counters are scaled by 1/ratio.
+}
+
+var (
+	// includeMethods the filters only measure the calls matching them, all calls if empty.
+	includeMethods []MethodPattern
+	// excludeMethods the filters do not measure the calls matching them.
+	excludeMethods []MethodPattern
+	// samplingRules sampling ratios of the calls, the first matched one is used.
+	samplingRules []SamplingConfig
+)
+
+// matchCallee reports whether the callee of the message matches the service and method patterns.
+func matchCallee(service, method string, msg codec.Msg) bool {
+	return matchPattern(service, msg.CalleeService()) && matchPattern(method, msg.CalleeMethod())
+}
+
+func checkPatterns(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// checkSampling validates the include, exclude and sampling configs.
+func checkSampling(include, exclude []MethodPattern, sampling []SamplingConfig) error {
+	for _, p := range append(append([]MethodPattern{}, include...), exclude...) {
+		if err := checkPatterns(p.Service, p.Method); err != nil {
+			return err
+		}
+	}
+	for _, c := range sampling {
+		if err := checkPatterns(c.Service, c.Method); err != nil {
+			return err
+		}
+		if c.Ratio <= 0 || c.Ratio > 1 {
+			return fmt.Errorf("sampling ratio %v of %s/%s should be in (0, 1]", c.Ratio, c.Service, c.Method)
+		}
+	}
+	return nil
+}
+
+// measured reports whether the filters measure the call by the include and exclude lists.
+func measured(msg codec.Msg) bool {
+	if len(includeMethods) > 0 && !matchMethodPatterns(includeMethods, msg) {
+		return false
+	}
+	return !matchMethodPatterns(excludeMethods, msg)
+}
+
+func matchMethodPatterns(patterns []MethodPattern, msg codec.Msg) bool {
+	for _, p := range patterns {
+		if matchCallee(p.Service, p.Method, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// sample decides whether to report the call, weight is the value counters of the call are scaled to.
+func sample(msg codec.Msg) (weight float64, ok bool) {
+	for _, c := range samplingRules {
+		if matchCallee(c.Service, c.Method, msg) {
+			if c.Ratio >= 1 {
+				return 1, true
+			}
+			return 1 / c.Ratio, rand.Float64() < c.Ratio
+		}
+	}
+	return 1, true
+}
@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/codec"
+)
+
+func TestMeasured(t *testing.T) {
+	defer func() { includeMethods, excludeMethods = nil, nil }()
+	_, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeService("trpc.app.server.Greeter")
+	msg.WithCalleeMethod("Health")
+	assert.True(t, measured(msg))
+
+	excludeMethods = []MethodPattern{{Method: "Health"}}
+	assert.False(t, measured(msg))
+	msg.WithCalleeMethod("Hello")
+	assert.True(t, measured(msg))
+
+	includeMethods = []MethodPattern{{Service: "trpc.app.server.*", Method: "Hello"}}
+	assert.True(t, measured(msg))
+	msg.WithCalleeMethod("Bye")
+	assert.False(t, measured(msg))
+}
+
+func TestSampling(t *testing.T) {
+	s, _ := useTestSink(t)
+	samplingRules = []SamplingConfig{{Method: "Sampled", Ratio: 0.5}, {Ratio: 1}}
+	excludeMethods = []MethodPattern{{Method: "Excluded"}}
+	defer func() { samplingRules, excludeMethods = nil, nil }()
+
+	const n = 2000
+	for i := 0; i < n; i++ {
+		callMethods("Sampled", "Excluded")
+	}
+	callMethods("Full")
+	v, ok := s.cache.Get("countervec_ServerFilter_requests")
+	assert.True(t, ok)
+	vec := v.(*prometheus.CounterVec)
+	assert.Equal(t, 2, testutil.CollectAndCount(vec))
+	values := func(method string) []string {
+		return []string{"", "", "Service", method, "", "", "", "", "0"}
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(vec.WithLabelValues(values("Full")...)))
+	assert.InDelta(t, n, testutil.ToFloat64(vec.WithLabelValues(values("Sampled")...)), n*0.2)
+}
+
+func TestCheckSampling(t *testing.T) {
+	assert.Nil(t, checkSampling([]MethodPattern{{Method: "Hello"}}, nil, []SamplingConfig{{Ratio: 0.1}}))
+	assert.NotNil(t, checkSampling([]MethodPattern{{Method: "["}}, nil, nil))
+	assert.NotNil(t, checkSampling(nil, []MethodPattern{{Service: "["}}, nil))
+	assert.NotNil(t, checkSampling(nil, nil, []SamplingConfig{{Ratio: 0}}))
+	assert.NotNil(t, checkSampling(nil, nil, []SamplingConfig{{Ratio: 1.5}}))
+	assert.NotNil(t, checkSampling(nil, nil, []SamplingConfig{{Method: "[", Ratio: 1}}))
+}
@@ -0,0 +1,80 @@
+package prometheus
+
+import (
+	"google.golang.org/protobuf/proto"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// defaultSizeBuckets default byte buckets of payload sizes, 64B to 16MB.
+var defaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216}
+
+// FilterSizeConfig request and response size histograms of the filters.
+type FilterSizeConfig struct {
+	Enable  bool      `yaml:"enable"`  //record request_size and response_size, disabled by default.
+	Buckets []float64 `yaml:"buckets"` //byte buckets, 64B to 16MB by default.
+}
+
+var (
+	// enableSize whether the filters record the payload sizes.
+	enableSize bool
+	// sizeBounds byte buckets of payload sizes.
+	sizeBounds = metrics.NewValueBounds(defaultSizeBuckets...)
+)
+
+// newSizeBounds validates the config and returns the byte buckets.
+func newSizeBounds(cfg FilterSizeConfig) (metrics.BucketBounds, error) {
+	if len(cfg.Buckets) == 0 {
+		return metrics.NewValueBounds(defaultSizeBuckets...), nil
+	}
+	if err := checkIncreasing(cfg.Buckets); err != nil {
+		return nil, err
+	}
+	return metrics.NewValueBounds(cfg.Buckets...), nil
+}
+
+// sizer is implemented by the messages which know their serialized size, such as gogo protobuf messages.
+type sizer interface {
+	Size() int
+}
+
+// payloadSize returns the serialized size of the request or response body, ok is false if it is unknown.
+func payloadSize(body interface{}) (size int, ok bool) {
+	switch b := body.(type) {
+	case nil:
+		return 0, false
+	case []byte:
+		return len(b), true
+	case *[]byte:
+		if b == nil {
+			return 0, false
+		}
+		return len(*b), true
+	case proto.Message:
+		return proto.Size(b), true
+	case sizer:
+		return b.Size(), true
+	default:
+		return 0, false
+	}
+}
+
+// appendSizeMetrics appends the size histograms of the known payloads if enabled, unit is the suffix of the names,
+// the response is not measured if the call fails.
+func appendSizeMetrics(ms []*metrics.Metrics, prefix, unit string, req, rsp interface{},
+	err error) []*metrics.Metrics {
+	if !enableSize {
+		return ms
+	}
+	if size, ok := payloadSize(req); ok {
+		metrics.Histogram(prefix+"_request_size"+unit, sizeBounds)
+		ms = append(ms, metrics.NewMetrics("request_size"+unit, float64(size), metrics.PolicyHistogram))
+	}
+	if err != nil {
+		return ms
+	}
+	if size, ok := payloadSize(rsp); ok {
+		metrics.Histogram(prefix+"_response_size"+unit, sizeBounds)
+		ms = append(ms, metrics.NewMetrics("response_size"+unit, float64(size), metrics.PolicyHistogram))
+	}
+	return ms
+}
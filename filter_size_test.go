@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+type testSizer struct{}
+
+func (testSizer) Size() int { return 42 }
+
+func TestPayloadSize(t *testing.T) {
+	for _, tt := range []struct {
+		body interface{}
+		size int
+		ok   bool
+	}{
+		{nil, 0, false},
+		{[]byte("abc"), 3, true},
+		{&[]byte{1, 2}, 2, true},
+		{wrapperspb.String("hello"), 7, true},
+		{testSizer{}, 42, true},
+		{struct{}{}, 0, false},
+	} {
+		size, ok := payloadSize(tt.body)
+		assert.Equal(t, tt.size, size)
+		assert.Equal(t, tt.ok, ok)
+	}
+}
+
+func TestSizeMetrics(t *testing.T) {
+	assert.Empty(t, appendSizeMetrics(nil, "ServerFilter", "", []byte("abc"), []byte("abcd"), nil))
+
+	enableSize = true
+	defer func() { enableSize = false }()
+	ms := appendSizeMetrics(nil, "ServerFilter", "", []byte("abc"), []byte("abcd"), nil)
+	assert.Len(t, ms, 2)
+	assert.Equal(t, "request_size", ms[0].Name())
+	assert.Equal(t, 3.0, ms[0].Value())
+	assert.Equal(t, "response_size", ms[1].Name())
+	assert.Equal(t, 4.0, ms[1].Value())
+	_, ok := metrics.GetHistogram("ServerFilter_request_size")
+	assert.True(t, ok)
+
+	// the response of a failed call is not measured.
+	ms = appendSizeMetrics(nil, "ClientFilter", "", []byte("abc"), []byte("abcd"), errors.New("failed"))
+	assert.Len(t, ms, 1)
+}
+
+func TestSizeBounds(t *testing.T) {
+	b, err := newSizeBounds(FilterSizeConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, metrics.NewValueBounds(defaultSizeBuckets...), b)
+	b, err = newSizeBounds(FilterSizeConfig{Buckets: []float64{100, 1000}})
+	assert.Nil(t, err)
+	assert.Equal(t, metrics.NewValueBounds(100, 1000), b)
+	_, err = newSizeBounds(FilterSizeConfig{Buckets: []float64{1000, 100}})
+	assert.NotNil(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 
 	"trpc.group/trpc-go/trpc-go/codec"
@@ -101,3 +102,28 @@ func TestSetBounds(t *testing.T) {
 	SetServerBounds(expectedBounds)
 	assert.Equal(t, expectedBounds, serverBounds)
 }
+
+// useTestSink reports the filter metrics to a new sink of its own registry until the test ends.
+func useTestSink(t *testing.T) (*Sink, *prometheus.Registry) {
+	registry := prometheus.NewRegistry()
+	s := NewSink(WithRegistry(registry))
+	defaultSink := GetDefaultPrometheusSink()
+	SetDefaultPrometheusSink(s)
+	t.Cleanup(func() { SetDefaultPrometheusSink(defaultSink) })
+	return s, registry
+}
+
+// histogramSum returns the sum of all series of the histogram.
+func histogramSum(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	mfs, err := registry.Gather()
+	assert.Nil(t, err)
+	var sum float64
+	for _, mf := range mfs {
+		if mf.GetName() == name {
+			for _, m := range mf.GetMetric() {
+				sum += m.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	return sum
+}
@@ -31,15 +31,28 @@ func (mc *metricsCache) Loader(key string, f createMetricFunc) interface{} {
 	mc.locker.RUnlock()
 
 	mc.locker.Lock()
+	defer mc.locker.Unlock()
 	if v, ok := mc.cache[key]; ok {
-		mc.locker.Unlock()
 		return v
 	}
 
 	// 执行创建函数
 	v := f()
 	mc.cache[key] = v
-	mc.locker.Unlock()
-
 	return v
 }
+
+// Get gets the indicator from the cache without creating it
+func (mc *metricsCache) Get(key string) (interface{}, bool) {
+	mc.locker.RLock()
+	v, ok := mc.cache[key]
+	mc.locker.RUnlock()
+	return v, ok
+}
+
+// Delete deletes the indicator from the cache
+func (mc *metricsCache) Delete(key string) {
+	mc.locker.Lock()
+	delete(mc.cache, key)
+	mc.locker.Unlock()
+}
@@ -15,6 +15,12 @@ func TestMetricsCache_Loader(t *testing.T) {
 	})
 
 	assert.Equal(t, 1, testValue)
+
+	// the cache is still usable after a panic in the create method.
+	assert.Panics(t, func() {
+		testCache.Loader("test_panic", func() interface{} { panic("create") })
+	})
+	assert.Equal(t, 1, testCache.Loader("test_other", func() interface{} { return 1 }))
 }
 func BenchmarkMetricsCache_Loader(b *testing.B) {
 	testCache := NewMetricsCache()
@@ -0,0 +1,139 @@
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+// SinkOption option of NewSink.
+type SinkOption func(*Sink)
+
+// WithRegistry sets the registry which registers and gathers the metrics of the sink.
+func WithRegistry(r *prometheus.Registry) SinkOption {
+	return func(s *Sink) {
+		s.registerer = r
+		s.gatherer = r
+	}
+}
+
+// WithNamespace sets the namespace of metrics.
+func WithNamespace(ns string) SinkOption {
+	return func(s *Sink) {
+		s.ns = ns
+	}
+}
+
+// WithSubsystem sets the subsystem of metrics.
+func WithSubsystem(subsystem string) SinkOption {
+	return func(s *Sink) {
+		s.subsystem = subsystem
+	}
+}
+
+// WithRawMode sets whether the special characters in metrics names are kept.
+func WithRawMode(rawMode bool) SinkOption {
+	return func(s *Sink) {
+		s.rawMode = rawMode
+	}
+}
+
+// WithConstLabels sets the labels attached to all metrics.
+func WithConstLabels(labels prometheus.Labels) SinkOption {
+	return func(s *Sink) {
+		s.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of histograms not registered by metrics.Histogram,
+// prometheus.DefBuckets is used by default. Buckets not in increasing order are ignored.
+func WithBuckets(buckets []float64) SinkOption {
+	return func(s *Sink) {
+		if err := checkIncreasing(buckets); err != nil {
+			log.Errorf("trpc-metrics-prometheus:buckets %v ignored:%v", buckets, err)
+			return
+		}
+		s.buckets = buckets
+	}
+}
+
+// WithPusher sets the pusher which pushes the metrics of the sink.
+func WithPusher(pusher *push.Pusher) SinkOption {
+	return func(s *Sink) {
+		s.pusher = pusher
+		s.enablePush = pusher != nil
+	}
+}
+
+// WithName sets the sink name, sinks of different names can be registered to trpc metrics together.
+func WithName(name string) SinkOption {
+	return func(s *Sink) {
+		s.name = name
+	}
+}
+
+// WithCardinality limits the number of series of each metric, the config of unknown overflow is ignored.
+func WithCardinality(cfg CardinalityConfig) SinkOption {
+	return func(s *Sink) {
+		if err := cfg.check(); err != nil {
+			log.Errorf("trpc-metrics-prometheus:cardinality ignored:%v", err)
+			return
+		}
+		s.cardinality = cfg
+	}
+}
+
+// WithSeriesTTL deletes the series of multi-dimension metrics idle longer than ttl, Close stops it.
+func WithSeriesTTL(ttl time.Duration) SinkOption {
+	return func(s *Sink) {
+		s.seriesTTL = ttl
+	}
+}
+
+// WithLabelConflict sets the behavior when the labels of a metric conflict with the registered ones,
+// LabelConflictReject by default, unknown behaviors are ignored.
+func WithLabelConflict(mode string) SinkOption {
+	return func(s *Sink) {
+		if err := checkLabelConflict(mode); err != nil {
+			log.Errorf("trpc-metrics-prometheus:%v", err)
+			return
+		}
+		s.labelConflict = mode
+	}
+}
+
+// WithHistogramBuckets sets the buckets of histograms whose names match the pattern, which is a metric name
+// or glob pattern. It takes precedence over metrics.Histogram, the first added matched one is used.
+// Invalid patterns and buckets not in increasing order are ignored.
+func WithHistogramBuckets(pattern string, buckets []float64) SinkOption {
+	return func(s *Sink) {
+		if _, err := (HistogramConfig{Match: pattern, Buckets: buckets}).buckets(); err != nil {
+			log.Errorf("trpc-metrics-prometheus:histogram %s ignored:%v", pattern, err)
+			return
+		}
+		s.bucketRules = append(s.bucketRules, bucketRule{pattern: pattern, buckets: buckets})
+	}
+}
+
+// WithNativeHistogram exports histograms as native histograms,
+// unless they are configured by the histograms of plugin config.
+func WithNativeHistogram(cfg NativeHistogramConfig) SinkOption {
+	return func(s *Sink) {
+		s.nativeHistogram = cfg
+	}
+}
+
+// WithSummary records the histograms and timers whose names match the pattern as summaries,
+// and configures the PolicyMID summaries. Only Objectives, MaxAge and AgeBuckets of opts are used,
+// invalid patterns and objectives are ignored.
+func WithSummary(pattern string, opts prometheus.SummaryOpts) SinkOption {
+	return func(s *Sink) {
+		if err := checkSummary(pattern, opts.Objectives); err != nil {
+			log.Errorf("trpc-metrics-prometheus:%v, ignored", err)
+			return
+		}
+		s.summaryRules = append(s.summaryRules, summaryRule{pattern: pattern, opts: opts})
+	}
+}
@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestNewSink(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	pusher := push.New("", "")
+	s := NewSink(
+		WithRegistry(registry),
+		WithNamespace("ns"),
+		WithSubsystem("sub"),
+		WithRawMode(true),
+		WithConstLabels(prometheus.Labels{"env": "test"}),
+		WithBuckets([]float64{1, 2}),
+		WithPusher(pusher),
+	)
+	assert.True(t, s.rawMode)
+	assert.True(t, s.enablePush)
+	assert.Equal(t, pusher, s.Pusher())
+
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_options", 1.5, metrics.PolicyHistogram))
+	mfs, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Len(t, mfs, 1)
+	assert.Equal(t, "ns_sub_test_options", mfs[0].GetName())
+	m := mfs[0].GetMetric()[0]
+	assert.Equal(t, "env", m.GetLabel()[0].GetName())
+	assert.Equal(t, "test", m.GetLabel()[0].GetValue())
+	assert.Len(t, m.GetHistogram().GetBucket(), 2)
+	assert.Equal(t, uint64(1), m.GetHistogram().GetBucket()[1].GetCumulativeCount())
+}
+
+func TestSetDefaultPrometheusSink(t *testing.T) {
+	old := GetDefaultPrometheusSink()
+	defer SetDefaultPrometheusSink(old)
+	s := NewSink()
+	SetDefaultPrometheusSink(s)
+	assert.Equal(t, s, GetDefaultPrometheusSink())
+}
+
+func TestWithBucketsInvalid(t *testing.T) {
+	s := NewSink(WithBuckets([]float64{2, 1}))
+	assert.Nil(t, s.buckets)
+	assert.Nil(t, s.Report(metrics.NewSingleDimensionMetrics("test_invalid_buckets", 1, metrics.PolicyHistogram)))
+}
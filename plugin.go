@@ -1,11 +1,20 @@
 package prometheus
 
 import (
+	"context"
+	"net"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/push"
+	"trpc.group/trpc-go/trpc-go/client"
 	"trpc.group/trpc-go/trpc-go/filter"
 	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/metrics"
 	"trpc.group/trpc-go/trpc-go/plugin"
+	"trpc.group/trpc-go/trpc-go/server"
 )
 
 const (
@@ -18,6 +27,9 @@ func init() {
 	plugin.Register(pluginName, &Plugin{})
 	//register filter.
 	filter.Register(pluginName, ServerFilter, ClientFilter)
+	//register stream filter.
+	server.RegisterStreamFilter(pluginName, StreamServerFilter)
+	client.RegisterStreamFilter(pluginName, StreamClientFilter)
 }
 
 // Config config struct.
@@ -33,6 +45,40 @@ type Config struct {
 	Gateway      string `yaml:"gateway"`      //push gateway address.
 	PushInterval uint32 `yaml:"pushinterval"` //push interval,default 1s.
 	Job          string `yaml:"job"`          //reported task name.
+	Window       uint32 `yaml:"window"`       //aggregation window of max/min gauges in seconds, default 60s.
+	// ShutdownTimeout timeout of gracefully shutting down the exporter in seconds, default 5s.
+	ShutdownTimeout uint32 `yaml:"shutdowntimeout"`
+	// IgnoreBindError keeps the service running without exporter if the port can not be bound,
+	// Setup fails by default.
+	IgnoreBindError bool `yaml:"ignorebinderror"`
+	// TLS serves metrics over https if certfile and keyfile are set.
+	TLS TLSConfig `yaml:"tls"`
+	// Auth protects the metrics path with basic auth or bearer token.
+	Auth AuthConfig `yaml:"auth"`
+	// IsolatedRegistry registers metrics to a registry owned by the plugin instead of the global default one,
+	// instances not named prometheus always own a registry.
+	IsolatedRegistry bool `yaml:"isolatedregistry"`
+	// DisableExporter does not start the exporter, which is useful for push only instances.
+	DisableExporter bool `yaml:"disableexporter"`
+	// Standalone does not register the sink to trpc metrics, report to it by GetSink(name) instead.
+	Standalone bool `yaml:"standalone"`
+	// Cardinality limits the number of series of each metric.
+	Cardinality CardinalityConfig `yaml:"cardinality"`
+	// SeriesTTL deletes the series of multi-dimension metrics idle longer than it in seconds, 0 means never.
+	SeriesTTL uint32 `yaml:"seriesttl"`
+	// LabelConflict behavior when the labels of a metric conflict with the registered ones, reject or merge.
+	LabelConflict string `yaml:"labelconflict"`
+	// Histograms buckets of histograms matching metric names or glob patterns, the first matched one is used.
+	Histograms []HistogramConfig `yaml:"histograms"`
+	// NativeHistogram exports histograms as native histograms unless configured by Histograms.
+	NativeHistogram NativeHistogramConfig `yaml:"nativehistogram"`
+	// Summaries records histograms and timers matching metric names or glob patterns as summaries.
+	Summaries []SummaryConfig `yaml:"summaries"`
+	// OpenMetrics serves OpenMetrics format with exemplars when the scraper asks for it, disabled by default,
+	// since it renders integer le and quantile label values like 1.0, which changes the identity of the series.
+	OpenMetrics bool `yaml:"openmetrics"`
+	// Filter dimensions of ServerFilter and ClientFilter metrics, only the default instance applies it.
+	Filter FilterConfig `yaml:"filter"`
 }
 
 // Default set default values
@@ -48,11 +94,81 @@ func (c Config) Default() *Config {
 		Gateway:      "",
 		PushInterval: 1,
 		Job:          "",
+		Window:       60,
+
+		ShutdownTimeout: 5,
+	}
+}
+
+var (
+	// defaultExporterAddr the address the exporter actually bound.
+	defaultExporterAddr net.Addr
+
+	// instances plugin instances which have been set up, keyed by name.
+	instances       = make(map[string]*Plugin)
+	instancesLocker sync.RWMutex
+)
+
+// GetExporterAddr returns the address the exporter actually bound, which is useful when port is 0.
+// It returns nil if the exporter failed to start.
+func GetExporterAddr() net.Addr {
+	return defaultExporterAddr
+}
+
+// Register registers a plugin instance configured by plugins.metrics.<name>,
+// it should be called before trpc.NewServer.
+func Register(name string) {
+	plugin.Register(name, &Plugin{})
+}
+
+// GetSink returns the sink of the plugin instance, it returns nil if the instance has not been set up.
+func GetSink(name string) *Sink {
+	if p := getInstance(name); p != nil {
+		return p.sink
 	}
+	return nil
+}
+
+// GetPusher returns the pusher of the plugin instance, it returns nil if the instance has not been set up.
+func GetPusher(name string) *push.Pusher {
+	if p := getInstance(name); p != nil {
+		return p.sink.pusher
+	}
+	return nil
+}
+
+// GetExporterAddrByName returns the address the exporter of the plugin instance actually bound,
+// it returns nil if the exporter is not running.
+func GetExporterAddrByName(name string) net.Addr {
+	if p := getInstance(name); p != nil {
+		return p.addr
+	}
+	return nil
+}
+
+func getInstance(name string) *Plugin {
+	instancesLocker.RLock()
+	defer instancesLocker.RUnlock()
+	p := instances[name]
+	if p == nil {
+		return nil
+	}
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.sink == nil {
+		return nil
+	}
+	return p
 }
 
 // Plugin plugin obj
 type Plugin struct {
+	locker          sync.Mutex
+	sink            *Sink
+	server          *http.Server
+	addr            net.Addr
+	pushLoop        *pushLoop
+	shutdownTimeout time.Duration
 }
 
 // Type plugin type
@@ -70,19 +186,100 @@ func (p *Plugin) Setup(name string, decoder plugin.Decoder) error {
 		log.Errorf("trpc-metrics-prometheus:conf Decode error:%v", err)
 		return err
 	}
-	go func() {
-		err := initMetrics(cfg.IP, cfg.Port, cfg.Path)
-		if err != nil {
-			log.Errorf("trpc-metrics-prometheus:running:%v", err)
-		}
-	}()
-	initSink(cfg)
+	if name != pluginName {
+		cfg.IsolatedRegistry = true
+	}
+	filterSettings, err := newFilterSettings(cfg.Filter)
+	if err != nil {
+		log.Errorf("trpc-metrics-prometheus:filter config error:%v", err)
+		return err
+	}
+	sink, err := newConfigSink(name, cfg)
+	if err != nil {
+		log.Errorf("trpc-metrics-prometheus:sink config error:%v", err)
+		return err
+	}
+	server, addr, err := setupExporter(cfg, sink)
+	if err != nil {
+		// stop the expirer of the sink, which is not used.
+		_ = sink.Close()
+		return err
+	}
+	// the instance named prometheus is the default one, or the first one if it is not configured.
+	if name == pluginName || defaultPrometheusSink == nil {
+		filterSettings.apply()
+		defaultPrometheusSink = sink
+		defaultPrometheusPusher = sink.pusher
+		defaultExporterAddr = addr
+	}
+	if !cfg.Standalone {
+		metrics.RegisterMetricsSink(sink)
+	}
+	l := startPusher(cfg, sink)
 
+	p.locker.Lock()
+	p.sink = sink
+	p.server = server
+	p.addr = addr
+	p.pushLoop = l
+	p.shutdownTimeout = time.Duration(cfg.ShutdownTimeout) * time.Second
+	p.locker.Unlock()
+
+	instancesLocker.Lock()
+	instances[name] = p
+	instancesLocker.Unlock()
 	return nil
 }
 
+// setupExporter starts the exporter of the sink, the server is nil if the exporter is not running.
+func setupExporter(cfg *Config, sink *Sink) (*http.Server, net.Addr, error) {
+	if cfg.DisableExporter {
+		return nil, nil, nil
+	}
+	server, err := newMetricsServer(cfg, sink.registerer, sink.gatherer)
+	if err != nil {
+		log.Errorf("trpc-metrics-prometheus:exporter config error:%v", err)
+		return nil, nil, err
+	}
+	addr, err := startMetricsServer(server, cfg.Path)
+	if err != nil {
+		if !cfg.IgnoreBindError {
+			log.Errorf("trpc-metrics-prometheus:listen error:%v", err)
+			return nil, nil, err
+		}
+		log.Warnf("trpc-metrics-prometheus:listen error:%v, running without exporter", err)
+		return nil, nil, nil
+	}
+	return server, addr, nil
+}
+
+// Close shuts down the exporter gracefully, stops the pusher and pushes the last interval of metrics.
+func (p *Plugin) Close() error {
+	p.locker.Lock()
+	server, l, timeout, sink := p.server, p.pushLoop, p.shutdownTimeout, p.sink
+	p.server, p.pushLoop = nil, nil
+	p.locker.Unlock()
+
+	var err error
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err = server.Shutdown(ctx); err != nil {
+			log.Errorf("trpc-metrics-prometheus:shutdown:%v", err)
+		}
+	}
+	if l != nil {
+		l.close()
+	}
+	if sink != nil {
+		_ = sink.Close()
+	}
+	return err
+}
+
 func basicAuthForPasswordOption(s string) (username, password string) {
-	splits := strings.Split(s, ":")
+	// the password may contain colons.
+	splits := strings.SplitN(s, ":", 2)
 	if len(splits) < 2 {
 		return
 	}
@@ -2,12 +2,16 @@
 package prometheus
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/model"
 	"trpc.group/trpc-go/trpc-go/log"
@@ -29,17 +33,48 @@ var (
 	mNameCache = newMetricsNameCache()
 )
 
-// initMetrics initialize metrics and metrics handler
-func initMetrics(ip string, port int32, path string) error {
+// newMetricsServer creates the exporter http server which exports the metrics of gatherer.
+func newMetricsServer(cfg *Config, registerer prometheus.Registerer, gatherer prometheus.Gatherer) (*http.Server, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Auth.check(); err != nil {
+		return nil, err
+	}
+	handler := promhttp.InstrumentMetricHandler(registerer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: cfg.OpenMetrics,
+	}))
+	if cfg.Auth.enabled() {
+		handler = authHandler(cfg.Auth, handler)
+	}
 	metricsHTTPHandler := http.NewServeMux()
-	metricsHTTPHandler.Handle(path, promhttp.Handler())
-	addr := fmt.Sprintf("%s:%d", ip, port)
-	server := &http.Server{
-		Addr:    addr,
-		Handler: metricsHTTPHandler,
+	metricsHTTPHandler.Handle(cfg.Path, handler)
+	return &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", cfg.IP, cfg.Port),
+		Handler:   metricsHTTPHandler,
+		TLSConfig: tlsConfig,
+	}, nil
+}
+
+// startMetricsServer binds the listener synchronously and serves in the background,
+// it returns the address actually bound.
+func startMetricsServer(server *http.Server, path string) (net.Addr, error) {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if server.TLSConfig != nil {
+		ln = tls.NewListener(ln, server.TLSConfig)
 	}
-	log.Infof("prometheus exporter running at %s, metrics path %s", addr, path)
-	return server.ListenAndServe()
+	log.Infof("prometheus exporter running at %s, metrics path %s", ln.Addr(), path)
+	go func() {
+		err := server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("trpc-metrics-prometheus:running:%v", err)
+		}
+	}()
+	return ln.Addr(), nil
 }
 
 // convertSpecialChars convert utf8 chars to _
@@ -3,12 +3,19 @@ package prometheus
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
+	"trpc.group/trpc-go/trpc-go/metrics"
+	"trpc.group/trpc-go/trpc-go/plugin"
 )
 
 var (
@@ -18,31 +25,28 @@ var (
 		Path: "/metrics",
 	}
 	once sync.Once
-	wg   sync.WaitGroup
 )
 
 func setup(t *testing.T) {
 	cfg := &yaml.Node{}
 	p := &Plugin{}
 	_ = p.Setup(pluginName, cfg)
-	wg.Add(1)
-	go func(w *sync.WaitGroup) {
-		w.Done()
-		once.Do(
-			func() {
-				// Waiting for a concurrent process to start
-				err := initMetrics(testCfg.IP, testCfg.Port, testCfg.Path)
-				if err != nil {
-					t.Error(err)
-					return
-				}
-			})
-	}(&wg)
+	once.Do(func() {
+		// the exporter of the default registry shared by the tests.
+		cfg := Config{}.Default()
+		cfg.IP, cfg.Port, cfg.Path = testCfg.IP, testCfg.Port, testCfg.Path
+		server, err := newMetricsServer(cfg, prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := startMetricsServer(server, cfg.Path); err != nil {
+			t.Fatal(err)
+		}
+	})
 }
 
 func getMetrics(t *testing.T) string {
 	time.Sleep(time.Second)
-	wg.Wait()
 	resp, err := http.Get(fmt.Sprintf("http://%s:%d%s", testCfg.IP, testCfg.Port, testCfg.Path))
 	if err != nil {
 		t.Fatal(err)
@@ -60,11 +64,110 @@ func getMetrics(t *testing.T) string {
 	return string(body)
 }
 
-func TestInitMetrics(t *testing.T) {
+func TestMetricsServer(t *testing.T) {
 	setup(t)
 	t.Log(getMetrics(t))
 }
 
+func TestPluginClose(t *testing.T) {
+	var pushed int32
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushed, 1)
+	}))
+	defer gateway.Close()
+
+	cfg := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal([]byte(fmt.Sprintf(`
+port: 9091
+enablepush: true
+gateway: %s
+job: test
+pushinterval: 3600
+`, gateway.URL)), cfg))
+	p := &Plugin{}
+	assert.Nil(t, p.Setup(pluginName, cfg))
+	time.Sleep(100 * time.Millisecond)
+	resp, err := http.Get("http://127.0.0.1:9091/metrics")
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+
+	assert.Nil(t, p.Close())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pushed))
+	_, err = http.Get("http://127.0.0.1:9091/metrics")
+	assert.NotNil(t, err)
+	// close twice is ok.
+	assert.Nil(t, p.Close())
+}
+
+func TestPluginBindError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	cfg := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal([]byte(fmt.Sprintf("port: %d", port)), cfg))
+	assert.NotNil(t, (&Plugin{}).Setup(pluginName, cfg))
+
+	assert.Nil(t, yaml.Unmarshal([]byte(fmt.Sprintf("{port: %d, ignorebinderror: true}", port)), cfg))
+	p := &Plugin{}
+	assert.Nil(t, p.Setup(pluginName, cfg))
+	assert.Nil(t, GetExporterAddr())
+	assert.Nil(t, p.Close())
+}
+
+func TestPluginEphemeralPort(t *testing.T) {
+	cfg := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal([]byte("port: 0"), cfg))
+	p := &Plugin{}
+	assert.Nil(t, p.Setup(pluginName, cfg))
+	defer p.Close()
+	addr := GetExporterAddr()
+	assert.NotNil(t, addr)
+	assert.NotEqual(t, 0, addr.(*net.TCPAddr).Port)
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", addr))
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+}
+
+func TestPluginInstances(t *testing.T) {
+	Register("prometheus_test_infra")
+	assert.NotNil(t, plugin.Get(pluginType, "prometheus_test_infra"))
+	assert.Nil(t, GetSink("prometheus_test_infra"))
+	assert.Nil(t, GetPusher("prometheus_test_infra"))
+
+	defaultSink := NewSink()
+	SetDefaultPrometheusSink(defaultSink)
+	defer SetDefaultPrometheusSink(nil)
+
+	cfg := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal([]byte("{port: 0, standalone: true, job: infra}"), cfg))
+	infra := plugin.Get(pluginType, "prometheus_test_infra")
+	assert.Nil(t, infra.Setup("prometheus_test_infra", cfg))
+	defer infra.(*Plugin).Close()
+	assert.Nil(t, yaml.Unmarshal([]byte("{port: 0, standalone: true, disableexporter: true}"), cfg))
+	biz := &Plugin{}
+	assert.Nil(t, biz.Setup("prometheus_test_biz", cfg))
+	defer biz.Close()
+
+	s := GetSink("prometheus_test_infra")
+	assert.NotNil(t, s)
+	assert.Equal(t, "prometheus_test_infra", s.Name())
+	assert.NotNil(t, GetPusher("prometheus_test_infra"))
+	assert.NotNil(t, GetExporterAddrByName("prometheus_test_infra"))
+	assert.Nil(t, GetExporterAddrByName("prometheus_test_biz"))
+	assert.Equal(t, defaultSink, GetDefaultPrometheusSink())
+
+	// instances own independent registries.
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_instance", 1, metrics.PolicySUM))
+	mfs, err := s.Gatherer().Gather()
+	assert.Nil(t, err)
+	assert.Equal(t, "Development_trpc_test_instance", mfs[0].GetName())
+	mfs, err = GetSink("prometheus_test_biz").Gatherer().Gather()
+	assert.Nil(t, err)
+	assert.Len(t, mfs, 0)
+}
+
 func TestConvertSpecialChars(t *testing.T) {
 	in := "trpc.Chinese Indicators"
 	out := convertSpecialChars(in)
@@ -0,0 +1,95 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Behaviors when the labels of a metric conflict with the ones it was registered with.
+const (
+	// LabelConflictReject rejects the record and returns ErrLabelConflict from Report.
+	LabelConflictReject = "reject"
+	// LabelConflictMerge fills the missing labels with empty values,
+	// records with labels not registered are still rejected.
+	LabelConflictMerge = "merge"
+)
+
+// ErrLabelConflict the labels of a record conflict with the ones its metric was registered with.
+var ErrLabelConflict = errors.New("labels conflict with registered labels")
+
+// checkLabelConflict checks the label conflict behavior.
+func checkLabelConflict(mode string) error {
+	switch mode {
+	case "", LabelConflictReject, LabelConflictMerge:
+		return nil
+	default:
+		return fmt.Errorf("unknown labelconflict %q, should be reject or merge", mode)
+	}
+}
+
+// labelSchemas records the label names each multi-dimension metric was registered with.
+type labelSchemas struct {
+	mode    string
+	locker  sync.RWMutex
+	schemas map[string][]string
+}
+
+func newLabelSchemas(mode string) *labelSchemas {
+	return &labelSchemas{
+		mode:    mode,
+		schemas: make(map[string][]string),
+	}
+}
+
+// align returns the labels and values in the order of the registered labels,
+// the labels are registered if the metric is reported for the first time.
+func (ls *labelSchemas) align(name string, labels, values []string) ([]string, []string, error) {
+	ls.locker.RLock()
+	schema, ok := ls.schemas[name]
+	ls.locker.RUnlock()
+	if !ok {
+		ls.locker.Lock()
+		if schema, ok = ls.schemas[name]; !ok {
+			schema = labels
+			ls.schemas[name] = labels
+		}
+		ls.locker.Unlock()
+	}
+	if equalLabels(schema, labels) {
+		return labels, values, nil
+	}
+
+	index := make(map[string]int, len(labels))
+	for i, l := range labels {
+		index[l] = i
+	}
+	aligned := make([]string, len(schema))
+	var missing int
+	for i, l := range schema {
+		j, ok := index[l]
+		if !ok {
+			missing++
+			continue
+		}
+		aligned[i] = values[j]
+	}
+	// labels not registered can not be merged.
+	extra := len(labels) - (len(schema) - missing)
+	if extra != 0 || (missing > 0 && ls.mode != LabelConflictMerge) {
+		return nil, nil, fmt.Errorf("metric %s labels %v, registered labels %v: %w", name, labels, schema, ErrLabelConflict)
+	}
+	return schema, aligned, nil
+}
+
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package prometheus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func reportDimensions(s *Sink, dims ...*metrics.Dimension) error {
+	return s.Report(metrics.NewMultiDimensionMetricsX("test_schema", dims,
+		[]*metrics.Metrics{metrics.NewMetrics("requests", 1, metrics.PolicySUM)}))
+}
+
+func TestLabelSchemaReorder(t *testing.T) {
+	s := NewSink()
+	assert.Nil(t, reportDimensions(s, &metrics.Dimension{Name: "a", Value: "1"}, &metrics.Dimension{Name: "b", Value: "2"}))
+	assert.Nil(t, reportDimensions(s, &metrics.Dimension{Name: "b", Value: "2"}, &metrics.Dimension{Name: "a", Value: "1"}))
+	vec := s.cache.Loader("countervec_test_schema_requests", nil).(*prometheus.CounterVec)
+	assert.Equal(t, 1, testutil.CollectAndCount(vec))
+	assert.Equal(t, float64(2), testutil.ToFloat64(vec.WithLabelValues("1", "2")))
+}
+
+func TestLabelSchemaReject(t *testing.T) {
+	s := NewSink()
+	assert.Nil(t, reportDimensions(s, &metrics.Dimension{Name: "a", Value: "1"}, &metrics.Dimension{Name: "b", Value: "2"}))
+	err := reportDimensions(s, &metrics.Dimension{Name: "a", Value: "1"})
+	assert.True(t, errors.Is(err, ErrLabelConflict))
+	err = reportDimensions(s, &metrics.Dimension{Name: "a", Value: "1"}, &metrics.Dimension{Name: "c", Value: "2"})
+	assert.True(t, errors.Is(err, ErrLabelConflict))
+}
+
+func TestLabelSchemaMerge(t *testing.T) {
+	s := NewSink(WithLabelConflict(LabelConflictMerge))
+	assert.Nil(t, reportDimensions(s, &metrics.Dimension{Name: "a", Value: "1"}, &metrics.Dimension{Name: "b", Value: "2"}))
+	assert.Nil(t, reportDimensions(s, &metrics.Dimension{Name: "b", Value: "3"}))
+	vec := s.cache.Loader("countervec_test_schema_requests", nil).(*prometheus.CounterVec)
+	assert.Equal(t, float64(1), testutil.ToFloat64(vec.WithLabelValues("", "3")))
+	// labels not registered can not be merged.
+	err := reportDimensions(s, &metrics.Dimension{Name: "c", Value: "1"})
+	assert.True(t, errors.Is(err, ErrLabelConflict))
+}
+
+func TestLabelConflictInvalid(t *testing.T) {
+	cfg := Config{}.Default()
+	cfg.LabelConflict = "merg"
+	_, err := newConfigSink("test", cfg)
+	assert.NotNil(t, err)
+
+	s := NewSink(WithLabelConflict("merg"))
+	assert.Equal(t, "", s.labelConflict)
+}
@@ -2,12 +2,14 @@ package prometheus
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/push"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"trpc.group/trpc-go/trpc-go/log"
 	"trpc.group/trpc-go/trpc-go/metrics"
 )
@@ -17,9 +19,6 @@ const (
 )
 
 var (
-	// prometheus only support one metrics registry once.
-	// use global cache.
-	cache     = NewMetricsCache()
 	errLength = errors.New("inconsistent labels and values length")
 
 	// defaultPrometheusPusher default prometheus pusher.
@@ -38,41 +37,124 @@ func GetDefaultPrometheusSink() *Sink {
 	return defaultPrometheusSink
 }
 
-func initSink(cfg *Config) {
-	defaultPrometheusPusher = push.New(cfg.Gateway, cfg.Job)
+// SetDefaultPrometheusSink sets the sink used by filters, it is set by plugin Setup by default.
+func SetDefaultPrometheusSink(s *Sink) {
+	defaultPrometheusSink = s
+}
+
+// newConfigSink creates the sink of plugin config.
+func newConfigSink(name string, cfg *Config) (*Sink, error) {
+	if err := cfg.Cardinality.check(); err != nil {
+		return nil, fmt.Errorf("cardinality: %w", err)
+	}
+	if err := checkLabelConflict(cfg.LabelConflict); err != nil {
+		return nil, err
+	}
+	rules, err := newBucketRules(cfg.Histograms)
+	if err != nil {
+		return nil, err
+	}
+	summaryRules, err := newSummaryRules(cfg.Summaries)
+	if err != nil {
+		return nil, err
+	}
+	pusher := push.New(cfg.Gateway, cfg.Job)
 	// set basic auth if set.
 	if len(cfg.Password) > 0 {
-		defaultPrometheusPusher.BasicAuth(basicAuthForPasswordOption(cfg.Password))
+		pusher.BasicAuth(basicAuthForPasswordOption(cfg.Password))
 	}
-	defaultPrometheusSink = &Sink{
-		ns:         cfg.Namespace,
-		subsystem:  cfg.Subsystem,
-		rawMode:    cfg.RawMode,
-		enablePush: cfg.EnablePush,
-		pusher:     defaultPrometheusPusher,
+	s := &Sink{
+		name:            name,
+		ns:              cfg.Namespace,
+		subsystem:       cfg.Subsystem,
+		rawMode:         cfg.RawMode,
+		enablePush:      cfg.EnablePush,
+		pusher:          pusher,
+		window:          time.Duration(cfg.Window) * time.Second,
+		registerer:      prometheus.DefaultRegisterer,
+		gatherer:        prometheus.DefaultGatherer,
+		cache:           NewMetricsCache(),
+		cardinality:     cfg.Cardinality,
+		seriesTTL:       time.Duration(cfg.SeriesTTL) * time.Second,
+		labelConflict:   cfg.LabelConflict,
+		bucketRules:     rules,
+		nativeHistogram: cfg.NativeHistogram,
+		summaryRules:    summaryRules,
 	}
-	metrics.RegisterMetricsSink(defaultPrometheusSink)
-	//start up pusher if needed.
-	if cfg.EnablePush {
-		defaultPrometheusPusher.Gatherer(prometheus.DefaultGatherer)
-		go pusherRun(cfg, defaultPrometheusPusher)
+	if cfg.IsolatedRegistry {
+		registry := prometheus.NewRegistry()
+		s.registerer, s.gatherer = registry, registry
+	}
+	s.schemas = newLabelSchemas(s.labelConflict)
+	s.initLimiter()
+	s.initExpirer()
+	return s, nil
+}
+
+// startPusher starts up pusher if needed.
+func startPusher(cfg *Config, s *Sink) *pushLoop {
+	if !cfg.EnablePush {
+		return nil
+	}
+	s.pusher.Gatherer(s.gatherer)
+	l := newPushLoop(s.pusher, time.Duration(cfg.PushInterval)*time.Second)
+	go l.run()
+	return l
+}
+
+// pushLoop pushes metrics to the pushgateway periodically until it is closed.
+type pushLoop struct {
+	pusher   *push.Pusher
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newPushLoop(pusher *push.Pusher, interval time.Duration) *pushLoop {
+	return &pushLoop{
+		pusher:   pusher,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 }
 
-// start up prometheus pusher.
-func pusherRun(cfg *Config, pusher *push.Pusher) {
-	ticker := time.NewTicker(time.Duration(cfg.PushInterval) * time.Second)
+// run start up prometheus pusher.
+func (l *pushLoop) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
 	for {
-		<-ticker.C
-		err := pusher.Push()
-		if err != nil {
-			log.Errorf("push result=%v", err)
+		select {
+		case <-ticker.C:
+			l.push()
+		case <-l.stop:
+			return
 		}
 	}
 }
 
-// Sink struct
+func (l *pushLoop) push() {
+	err := l.pusher.Push()
+	if err != nil {
+		log.Errorf("push result=%v", err)
+	}
+}
+
+// close stops the loop and pushes once more, so that the last interval is not lost.
+func (l *pushLoop) close() {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+		l.push()
+	})
+}
+
+// Sink struct, the zero value Sink registers its metrics to the default registry.
 type Sink struct {
+	//name sink name, which is the key of trpc metrics sinks.
+	name string
 	//ns namespace for metrics.
 	ns string
 	//subsystem ns.
@@ -83,11 +165,184 @@ type Sink struct {
 	enablePush bool
 	//Pusher manages a push to the pushgateway.
 	pusher *push.Pusher
+	//window aggregation window of PolicyMAX and PolicyMIN gauges.
+	window time.Duration
+	//registerer registers the metrics of the sink.
+	registerer prometheus.Registerer
+	//gatherer gathers the metrics of the sink for exporter and pusher.
+	gatherer prometheus.Gatherer
+	//cache created metrics.
+	cache *metricsCache
+	//constLabels labels attached to all metrics of the sink.
+	constLabels prometheus.Labels
+	//buckets default histogram buckets.
+	buckets []float64
+	//cardinality limits the number of series of each metric.
+	cardinality CardinalityConfig
+	//limiter rejects the series beyond the limit, nil if not limited.
+	limiter *seriesLimiter
+	//seriesTTL series idle longer than it are deleted, 0 means never.
+	seriesTTL time.Duration
+	//expirer deletes the idle series, nil if seriesTTL is 0.
+	expirer *seriesExpirer
+	//labelConflict behavior when labels conflict with the registered ones.
+	labelConflict string
+	//schemas registered labels of multi-dimension metrics.
+	schemas *labelSchemas
+	//bucketRules buckets of histograms matching the patterns, the first matched one is used.
+	bucketRules []bucketRule
+	//nativeHistogram native histogram config of histograms not configured by bucketRules.
+	nativeHistogram NativeHistogramConfig
+	//summaryRules histograms and timers matching the patterns are recorded as summaries.
+	summaryRules []summaryRule
+	//vecKeys metric name => cache keys of the vectors it was reported to.
+	vecKeys   map[string][]string
+	vecLocker sync.Mutex
+	//initOnce initializes the fields not set, so that the zero value Sink reports to the default registry.
+	initOnce sync.Once
+}
+
+// NewSink creates a sink which owns a new registry unless WithRegistry is set.
+func NewSink(opts ...SinkOption) *Sink {
+	registry := prometheus.NewRegistry()
+	s := &Sink{
+		name:       sinkName,
+		window:     defaultWindow,
+		registerer: registry,
+		gatherer:   registry,
+		cache:      NewMetricsCache(),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	if s.pusher != nil {
+		s.pusher.Gatherer(s.gatherer)
+	}
+	s.schemas = newLabelSchemas(s.labelConflict)
+	s.initLimiter()
+	s.initExpirer()
+	return s
+}
+
+// lazyInit initializes the cache, registry and label schemas if they are not set,
+// a zero value Sink registers its metrics to the default registry.
+func (s *Sink) lazyInit() {
+	s.initOnce.Do(func() {
+		if s.cache == nil {
+			s.cache = NewMetricsCache()
+		}
+		if s.registerer == nil {
+			s.registerer = prometheus.DefaultRegisterer
+		}
+		if s.gatherer == nil {
+			s.gatherer = prometheus.DefaultGatherer
+		}
+		if s.schemas == nil {
+			s.schemas = newLabelSchemas(s.labelConflict)
+		}
+	})
+}
+
+// load loads the metric from the cache, it is created by f if not cached.
+func (s *Sink) load(key string, f createMetricFunc) interface{} {
+	s.lazyInit()
+	return s.cache.Loader(key, f)
+}
+
+// initExpirer starts up the expirer if series ttl is set.
+func (s *Sink) initExpirer() {
+	if s.seriesTTL <= 0 {
+		return
+	}
+	s.expirer = newSeriesExpirer(s.seriesTTL, s.deleteSeries)
+	go s.expirer.run()
+}
+
+// deleteSeries deletes the series of the multi-dimension metric from all vectors it was reported to.
+func (s *Sink) deleteSeries(name string, values []string) {
+	s.vecLocker.Lock()
+	vecKeys := s.vecKeys[name]
+	s.vecLocker.Unlock()
+	for _, key := range vecKeys {
+		if v, ok := s.cache.Get(key); ok {
+			if vec, ok := v.(interface{ DeleteLabelValues(...string) bool }); ok {
+				vec.DeleteLabelValues(values...)
+			}
+		}
+	}
+	if s.limiter != nil {
+		s.limiter.forget(name, values)
+	}
+}
+
+// trackVec records the cache key of the vector the metric is reported to, it is called when the vector is created.
+func (s *Sink) trackVec(name, cacheKey string) {
+	s.vecLocker.Lock()
+	defer s.vecLocker.Unlock()
+	if s.vecKeys == nil {
+		s.vecKeys = make(map[string][]string)
+	}
+	s.vecKeys[name] = append(s.vecKeys[name], cacheKey)
+}
+
+// Close stops the background goroutines of the sink.
+func (s *Sink) Close() error {
+	if s.expirer != nil {
+		s.expirer.close()
+	}
+	return nil
+}
+
+// initLimiter creates the series limiter if the series are limited.
+func (s *Sink) initLimiter() {
+	if s.cardinality.MaxSeries <= 0 && len(s.cardinality.Limits) == 0 {
+		return
+	}
+	rejected, ok := s.register(newRejectedCounter()).(*prometheus.CounterVec)
+	if !ok {
+		return
+	}
+	s.limiter = newSeriesLimiter(s.cardinality, rejected)
+}
+
+// Pusher returns the pusher of the sink, it is nil if not set.
+func (s *Sink) Pusher() *push.Pusher {
+	return s.pusher
+}
+
+// Registerer returns the registerer of the sink, collectors registered to it are exported together.
+func (s *Sink) Registerer() prometheus.Registerer {
+	s.lazyInit()
+	return s.registerer
+}
+
+// Gatherer returns the gatherer of the sink.
+func (s *Sink) Gatherer() prometheus.Gatherer {
+	s.lazyInit()
+	return s.gatherer
+}
+
+// register registers the collector, the existing one is returned if it has been registered.
+func (s *Sink) register(c prometheus.Collector) prometheus.Collector {
+	s.lazyInit()
+	err := s.registerer.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) && reflect.TypeOf(are.ExistingCollector) == reflect.TypeOf(c) {
+		return are.ExistingCollector
+	}
+	log.Errorf("trpc-metrics-prometheus:register error:%v", err)
+	return c
 }
 
 // Name return sink name.
 func (s *Sink) Name() string {
-	return sinkName
+	if s.name == "" {
+		return sinkName
+	}
+	return s.name
 }
 
 // GetMetricsName returns metrics name.
@@ -101,12 +356,15 @@ func (s *Sink) GetMetricsName(m *metrics.Metrics) string {
 
 // Report report.
 func (s *Sink) Report(rec metrics.Record, opts ...metrics.Option) error {
+	s.lazyInit()
 	if len(rec.GetDimensions()) <= 0 {
 		return s.ReportSingleLabel(rec, opts...)
 	}
 	labels := make([]string, 0)
 	values := make([]string, 0)
 	prefix := rec.GetName()
+	exemplar := getExemplar(opts...)
+	layout := getBucketLayout(opts...)
 
 	if len(labels) != len(values) {
 		return errLength
@@ -116,6 +374,7 @@ func (s *Sink) Report(rec metrics.Record, opts ...metrics.Option) error {
 		labels = append(labels, dimension.Name)
 		values = append(values, dimension.Value)
 	}
+	var reportErr error
 	for _, m := range rec.GetMetrics() {
 		name := s.GetMetricsName(m)
 		if prefix != "" {
@@ -125,45 +384,135 @@ func (s *Sink) Report(rec metrics.Record, opts ...metrics.Option) error {
 			log.Errorf("metrics %s(%s) is invalid", name, m.Name())
 			continue
 		}
-		s.reportVec(name, m, labels, values)
+		l, v, err := s.schemas.align(name, labels, values)
+		if err != nil {
+			log.Errorf("trpc-metrics-prometheus:%v", err)
+			if reportErr == nil {
+				reportErr = err
+			}
+			continue
+		}
+		s.reportVec(name, m, l, v, exemplar, layout)
 	}
-	return nil
+	return reportErr
 }
 
-func (s *Sink) reportVec(name string, m *metrics.Metrics, labels, values []string) {
+func (s *Sink) reportVec(name string, m *metrics.Metrics, labels, values []string, exemplar prometheus.Labels,
+	layout *bucketLayout) {
+	if s.limiter != nil {
+		overflow := overflowLabelValue
+		if layout != nil && m.Policy() == metrics.PolicyHistogram && !s.isSummary(name) {
+			overflow = layoutOverflowValue(layout)
+		}
+		var ok bool
+		if values, ok = s.limiter.admit(name, values, overflow); !ok {
+			return
+		}
+	}
+	if s.expirer != nil {
+		s.expirer.touch(name, values)
+	}
 	switch m.Policy() {
 	case metrics.PolicySUM:
 		s.incrCounterVec(name, m.Value(), labels, values)
 	case metrics.PolicySET:
 		s.setGaugeVec(name, m.Value(), labels, values)
 	case metrics.PolicyHistogram:
-		s.addSampleVec(name, m.Value(), labels, values)
+		switch {
+		case s.isSummary(name):
+			s.addSummaryVec(name, m.Value(), labels, values)
+		case layout != nil:
+			s.addLayoutSampleVec(name, m.Value(), labels, values, layout, exemplar)
+		default:
+			s.addSampleVec(name, m.Value(), labels, values, exemplar)
+		}
+	case metrics.PolicyAVG:
+		s.addAvgVec(name, m.Value(), labels, values)
+	case metrics.PolicyMAX, metrics.PolicyMIN:
+		s.setWindowGaugeVec(name, m.Value(), m.Policy(), labels, values)
+	case metrics.PolicyMID:
+		s.addSummaryVec(name, m.Value(), labels, values)
+	case metrics.PolicyTimer:
+		if s.isSummary(name) {
+			s.addSummaryVec(name, time.Duration(m.Value()).Seconds(), labels, values)
+		} else {
+			s.addTimerVec(name, m.Value(), labels, values, exemplar)
+		}
 	default:
 		log.Warnf("trpc-metrics-prometheus Policy not support %d", m.Policy())
 	}
 }
 
+// addGauge adds delta to the gauge of the multi-dimension metric, such as the in-flight requests.
+// The series are not expired by the series ttl, which would lose the deltas of the series.
+func (s *Sink) addGauge(name string, dimensions []*metrics.Dimension, delta float64) error {
+	s.lazyInit()
+	if !s.rawMode {
+		name = convertSpecialCharsWithCache(name)
+	}
+	if !checkMetricsValid(name) {
+		log.Errorf("metrics %s is invalid", name)
+		return nil
+	}
+	labels := make([]string, 0, len(dimensions))
+	values := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		labels = append(labels, dimension.Name)
+		values = append(values, dimension.Value)
+	}
+	labels, values, err := s.schemas.align(name, labels, values)
+	if err != nil {
+		log.Errorf("trpc-metrics-prometheus:%v", err)
+		return err
+	}
+	if s.limiter != nil {
+		var ok bool
+		if values, ok = s.limiter.admit(name, values, overflowLabelValue); !ok {
+			return nil
+		}
+	}
+	s.addGaugeVec(name, delta, labels, values)
+	return nil
+}
+
 // ReportSingleLabel single indicator report.
 func (s *Sink) ReportSingleLabel(rec metrics.Record, opts ...metrics.Option) error {
+	exemplar := getExemplar(opts...)
 	for _, m := range rec.GetMetrics() {
 		name := s.GetMetricsName(m)
 		if !checkMetricsValid(name) {
 			log.Errorf("metrics %s(%s) is invalid", name, m.Name())
 			continue
 		}
-		s.report(name, m)
+		s.report(name, m, exemplar)
 	}
 	return nil
 }
 
-func (s *Sink) report(name string, m *metrics.Metrics) {
+func (s *Sink) report(name string, m *metrics.Metrics, exemplar prometheus.Labels) {
 	switch m.Policy() {
 	case metrics.PolicySUM:
 		s.incrCounter(name, m.Value())
 	case metrics.PolicySET:
 		s.setGauge(name, m.Value())
 	case metrics.PolicyHistogram:
-		s.addSample(name, m.Value())
+		if s.isSummary(name) {
+			s.addSummary(name, m.Value())
+		} else {
+			s.addSample(name, m.Value(), exemplar)
+		}
+	case metrics.PolicyAVG:
+		s.addAvg(name, m.Value())
+	case metrics.PolicyMAX, metrics.PolicyMIN:
+		s.setWindowGauge(name, m.Value(), m.Policy())
+	case metrics.PolicyMID:
+		s.addSummary(name, m.Value())
+	case metrics.PolicyTimer:
+		if s.isSummary(name) {
+			s.addSummary(name, time.Duration(m.Value()).Seconds())
+		} else {
+			s.addTimer(name, m.Value(), exemplar)
+		}
 	default:
 		log.Warnf("trpc-metrics-prometheus Policy not support %d", m.Policy())
 	}
@@ -171,13 +520,15 @@ func (s *Sink) report(name string, m *metrics.Metrics) {
 
 func (s *Sink) incrCounterVec(key string, value float64, labels []string, values []string) {
 	cacheKey := "countervec_" + key
-	v := cache.Loader(cacheKey, func() interface{} {
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
 		// Create metrics.
-		return promauto.NewCounterVec(prometheus.CounterOpts{
-			Namespace: s.ns,
-			Subsystem: s.subsystem,
-			Name:      key,
-		}, labels)
+		return s.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   s.ns,
+			Subsystem:   s.subsystem,
+			Name:        key,
+			ConstLabels: s.constLabels,
+		}, labels))
 	})
 
 	counterVec := v.(*prometheus.CounterVec)
@@ -186,12 +537,13 @@ func (s *Sink) incrCounterVec(key string, value float64, labels []string, values
 
 func (s *Sink) incrCounter(key string, value float64) {
 	cacheKey := "counter_" + key
-	v := cache.Loader(cacheKey, func() interface{} {
-		return promauto.NewCounter(prometheus.CounterOpts{
-			Namespace: s.ns,
-			Subsystem: s.subsystem,
-			Name:      key,
-		})
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   s.ns,
+			Subsystem:   s.subsystem,
+			Name:        key,
+			ConstLabels: s.constLabels,
+		}))
 	})
 
 	counter := v.(prometheus.Counter)
@@ -200,12 +552,13 @@ func (s *Sink) incrCounter(key string, value float64) {
 
 func (s *Sink) setGauge(key string, value float64) {
 	cacheKey := "gauge_" + key
-	v := cache.Loader(cacheKey, func() interface{} {
-		return promauto.NewGauge(prometheus.GaugeOpts{
-			Namespace: s.ns,
-			Subsystem: s.subsystem,
-			Name:      key,
-		})
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   s.ns,
+			Subsystem:   s.subsystem,
+			Name:        key,
+			ConstLabels: s.constLabels,
+		}))
 	})
 
 	gauge := v.(prometheus.Gauge)
@@ -214,60 +567,245 @@ func (s *Sink) setGauge(key string, value float64) {
 
 func (s *Sink) setGaugeVec(key string, value float64, labels []string, values []string) {
 	cacheKey := "gaugevec_" + key
-	v := cache.Loader(cacheKey, func() interface{} {
-		return promauto.NewGaugeVec(prometheus.GaugeOpts{
-			Namespace: s.ns,
-			Subsystem: s.subsystem,
-			Name:      key,
-		}, labels)
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   s.ns,
+			Subsystem:   s.subsystem,
+			Name:        key,
+			ConstLabels: s.constLabels,
+		}, labels))
 	})
 
 	gaugeVec := v.(*prometheus.GaugeVec)
 	gaugeVec.WithLabelValues(values...).Set(value)
 }
 
-func (s *Sink) addSample(key string, value float64) {
-	cacheKey := "histogram_" + key
-	v := cache.Loader(cacheKey, func() interface{} {
-		var buckets []float64
-		if h, ok := metrics.GetHistogram(key); ok {
-			for _, b := range h.GetBuckets() {
-				buckets = append(buckets, b.ValueUpperBound)
-			}
-		} else {
-			buckets = prometheus.DefBuckets
+func (s *Sink) addGaugeVec(key string, value float64, labels []string, values []string) {
+	cacheKey := "gaugevec_" + key
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   s.ns,
+			Subsystem:   s.subsystem,
+			Name:        key,
+			ConstLabels: s.constLabels,
+		}, labels))
+	})
+
+	gaugeVec := v.(*prometheus.GaugeVec)
+	gaugeVec.WithLabelValues(values...).Add(value)
+}
+
+// ruleBuckets returns the buckets of the first rule matching the key.
+func (s *Sink) ruleBuckets(key string) ([]float64, bool) {
+	if r, ok := s.histogramRule(key); ok && r.buckets != nil {
+		return r.buckets, true
+	}
+	return nil, false
+}
+
+// histogramRule returns the first rule matching the key.
+func (s *Sink) histogramRule(key string) (bucketRule, bool) {
+	for _, r := range s.bucketRules {
+		if r.match(key) {
+			return r, true
 		}
-		return promauto.NewHistogram(prometheus.HistogramOpts{
-			Namespace: s.ns,
-			Subsystem: s.subsystem,
-			Name:      key,
-			Buckets:   buckets,
-		})
+	}
+	return bucketRule{}, false
+}
+
+// histogramOpts returns the opts of the histogram, native histogram is enabled by the rule matching the key,
+// or by the sink.
+func (s *Sink) histogramOpts(key string, buckets []float64) prometheus.HistogramOpts {
+	opts := prometheus.HistogramOpts{
+		Namespace:   s.ns,
+		Subsystem:   s.subsystem,
+		Name:        key,
+		ConstLabels: s.constLabels,
+		Buckets:     buckets,
+	}
+	native := s.nativeHistogram
+	if r, ok := s.histogramRule(key); ok && r.native != nil {
+		native = *r.native
+	}
+	if native.Enable {
+		native.apply(&opts)
+	}
+	return opts
+}
+
+// timerBuckets returns the buckets of timers, which are in seconds.
+func (s *Sink) timerBuckets(key string) []float64 {
+	if buckets, ok := s.ruleBuckets(key); ok {
+		return buckets
+	}
+	return prometheus.DefBuckets
+}
+
+// histogramBuckets returns the buckets configured by rules, or registered by metrics.Histogram,
+// or the default buckets of the sink.
+func (s *Sink) histogramBuckets(key string) []float64 {
+	if buckets, ok := s.ruleBuckets(key); ok {
+		return buckets
+	}
+	h, ok := metrics.GetHistogram(key)
+	if !ok {
+		if len(s.buckets) > 0 {
+			return s.buckets
+		}
+		return prometheus.DefBuckets
+	}
+	var buckets []float64
+	for _, b := range h.GetBuckets() {
+		buckets = append(buckets, b.ValueUpperBound)
+	}
+	return buckets
+}
+
+func (s *Sink) addSample(key string, value float64, exemplar prometheus.Labels) {
+	cacheKey := "histogram_" + key
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(prometheus.NewHistogram(s.histogramOpts(key, s.histogramBuckets(key))))
 	})
 
 	histogram := v.(prometheus.Histogram)
-	histogram.Observe(value)
+	observe(histogram, value, exemplar)
 }
 
-func (s *Sink) addSampleVec(key string, value float64, labels []string, values []string) {
+func (s *Sink) addSampleVec(key string, value float64, labels []string, values []string,
+	exemplar prometheus.Labels) {
 	cacheKey := "histogramvec_" + key
-	v := cache.Loader(cacheKey, func() interface{} {
-		var buckets []float64
-		if h, ok := metrics.GetHistogram(key); ok {
-			for _, b := range h.GetBuckets() {
-				buckets = append(buckets, b.ValueUpperBound)
-			}
-		} else {
-			buckets = prometheus.DefBuckets
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(prometheus.NewHistogramVec(s.histogramOpts(key, s.histogramBuckets(key)), labels))
+	})
+
+	histogramVec := v.(*prometheus.HistogramVec)
+	observe(histogramVec.WithLabelValues(values...), value, exemplar)
+}
+
+// addLayoutSampleVec observes the histogram of the bucket layout, which is registered unchecked
+// to be exported with the other layouts under the same name.
+func (s *Sink) addLayoutSampleVec(key string, value float64, labels []string, values []string,
+	layout *bucketLayout, exemplar prometheus.Labels) {
+	cacheKey := "histogramvec_" + key + "\xff" + layout.name
+	v := s.load(cacheKey, func() interface{} {
+		vec := prometheus.NewHistogramVec(s.histogramOpts(key, layout.buckets), labels)
+		if err := s.registerer.Register(uncheckedCollector{vec}); err != nil {
+			log.Errorf("trpc-metrics-prometheus:register error:%v", err)
 		}
-		return promauto.NewHistogramVec(prometheus.HistogramOpts{
-			Namespace: s.ns,
-			Subsystem: s.subsystem,
-			Name:      key,
-			Buckets:   buckets,
-		}, labels)
+		s.trackVec(key, cacheKey)
+		return vec
+	})
+
+	histogramVec := v.(*prometheus.HistogramVec)
+	observe(histogramVec.WithLabelValues(values...), value, exemplar)
+}
+
+// setWindowGauge sets the gauge to the max or min value of the current window.
+func (s *Sink) setWindowGauge(key string, value float64, policy metrics.Policy) {
+	cacheKey := "windowgauge_" + key
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(newWindowGaugeVec(s.gaugeOpts(key), nil, policy, s.window))
+	})
+
+	v.(*windowGaugeVec).Observe(value)
+}
+
+func (s *Sink) setWindowGaugeVec(key string, value float64, policy metrics.Policy, labels []string,
+	values []string) {
+	cacheKey := "windowgaugevec_" + key
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(newWindowGaugeVec(s.gaugeOpts(key), labels, policy, s.window))
+	})
+
+	v.(*windowGaugeVec).Observe(value, values...)
+}
+
+// gaugeOpts returns the opts of the gauge.
+func (s *Sink) gaugeOpts(key string) prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace:   s.ns,
+		Subsystem:   s.subsystem,
+		Name:        key,
+		ConstLabels: s.constLabels,
+	}
+}
+
+// addAvg observes a PolicyAVG value by a summary without quantiles, which exports the sum and count.
+func (s *Sink) addAvg(key string, value float64) {
+	cacheKey := "avg_" + key
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(prometheus.NewSummary(s.avgOpts(key)))
+	})
+
+	summary := v.(prometheus.Summary)
+	summary.Observe(value)
+}
+
+func (s *Sink) addAvgVec(key string, value float64, labels []string, values []string) {
+	cacheKey := "avgvec_" + key
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(prometheus.NewSummaryVec(s.avgOpts(key), labels))
+	})
+
+	summaryVec := v.(*prometheus.SummaryVec)
+	summaryVec.WithLabelValues(values...).Observe(value)
+}
+
+// avgOpts returns the opts of the PolicyAVG summary, which has no objectives.
+func (s *Sink) avgOpts(key string) prometheus.SummaryOpts {
+	return prometheus.SummaryOpts{
+		Namespace:   s.ns,
+		Subsystem:   s.subsystem,
+		Name:        key,
+		ConstLabels: s.constLabels,
+	}
+}
+
+func (s *Sink) addSummary(key string, value float64) {
+	cacheKey := "summary_" + key
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(prometheus.NewSummary(s.summaryOpts(key)))
+	})
+
+	summary := v.(prometheus.Summary)
+	summary.Observe(value)
+}
+
+func (s *Sink) addSummaryVec(key string, value float64, labels []string, values []string) {
+	cacheKey := "summaryvec_" + key
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(prometheus.NewSummaryVec(s.summaryOpts(key), labels))
+	})
+
+	summaryVec := v.(*prometheus.SummaryVec)
+	summaryVec.WithLabelValues(values...).Observe(value)
+}
+
+// addTimer observes a PolicyTimer value, which is a time.Duration, in seconds.
+func (s *Sink) addTimer(key string, value float64, exemplar prometheus.Labels) {
+	cacheKey := "timer_" + key
+	v := s.load(cacheKey, func() interface{} {
+		return s.register(prometheus.NewHistogram(s.histogramOpts(key, s.timerBuckets(key))))
+	})
+
+	histogram := v.(prometheus.Histogram)
+	observe(histogram, time.Duration(value).Seconds(), exemplar)
+}
+
+func (s *Sink) addTimerVec(key string, value float64, labels []string, values []string,
+	exemplar prometheus.Labels) {
+	cacheKey := "timervec_" + key
+	v := s.load(cacheKey, func() interface{} {
+		s.trackVec(key, cacheKey)
+		return s.register(prometheus.NewHistogramVec(s.histogramOpts(key, s.timerBuckets(key)), labels))
 	})
 
 	histogramVec := v.(*prometheus.HistogramVec)
-	histogramVec.WithLabelValues(values...).Observe(value)
+	observe(histogramVec.WithLabelValues(values...), time.Duration(value).Seconds(), exemplar)
 }
@@ -3,8 +3,13 @@ package prometheus
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
 
 	"trpc.group/trpc-go/trpc-go/metrics"
 	runtime "trpc.group/trpc-go/trpc-metrics-runtime"
@@ -19,12 +24,13 @@ func TestSink(t *testing.T) {
 	i := float64(0)
 	for i <= 3 {
 		s.incrCounter("test_counter", 100*i)
-		s.addSample("test_sample", 200*i)
+		s.addSample("test_sample", 200*i, nil)
 		s.setGauge("test_gauge", 300*i)
 		_ = s.Report(metrics.NewSingleDimensionMetrics("test_counter_中文", 1, metrics.PolicySUM))
 
 		// Test multi-dimensional, multi-record reporting.
-		for _, policy := range []metrics.Policy{metrics.PolicySUM, metrics.PolicySET, metrics.PolicyHistogram} {
+		for _, policy := range []metrics.Policy{metrics.PolicySUM, metrics.PolicySET, metrics.PolicyHistogram,
+			metrics.PolicyAVG, metrics.PolicyMAX, metrics.PolicyMIN, metrics.PolicyMID, metrics.PolicyTimer} {
 			// report labels from 0 to 20
 			ms := make([]*metrics.Metrics, 0)
 			labels := make([]*metrics.Dimension, 0)
@@ -48,6 +54,105 @@ func TestSink(t *testing.T) {
 	t.Log(getMetrics(t))
 }
 
+func TestZeroSink(t *testing.T) {
+	s := &Sink{}
+	assert.Nil(t, s.Report(metrics.NewSingleDimensionMetrics("test_zero_sink", 1, metrics.PolicySUM)))
+	assert.Nil(t, s.Report(metrics.NewMultiDimensionMetricsX("test_zero_sink_vec",
+		[]*metrics.Dimension{{Name: "a", Value: "1"}}, []*metrics.Metrics{metrics.NewMetrics("requests", 1, metrics.PolicySUM)})))
+	assert.Equal(t, prometheus.DefaultGatherer, s.Gatherer())
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	assert.Nil(t, err)
+	var names []string
+	for _, mf := range mfs {
+		names = append(names, mf.GetName())
+	}
+	assert.Contains(t, names, "test_zero_sink")
+	assert.Contains(t, names, "test_zero_sink_vec_requests")
+}
+
+func TestSinkPolicies(t *testing.T) {
+	s := NewSink()
+	s.window = time.Hour
+	for _, v := range []float64{3, 1, 5, 2} {
+		_ = s.Report(metrics.NewSingleDimensionMetrics("test_policy_max", v, metrics.PolicyMAX))
+		_ = s.Report(metrics.NewSingleDimensionMetrics("test_policy_min", v, metrics.PolicyMIN))
+		_ = s.Report(metrics.NewSingleDimensionMetrics("test_policy_avg", v, metrics.PolicyAVG))
+		_ = s.Report(metrics.NewSingleDimensionMetrics("test_policy_mid", v, metrics.PolicyMID))
+		_ = s.Report(metrics.NewSingleDimensionMetrics("test_policy_timer", float64(time.Second), metrics.PolicyTimer))
+	}
+	assert.Equal(t, float64(5), testutil.ToFloat64(s.cache.Loader("windowgauge_test_policy_max", nil).(*windowGaugeVec)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(s.cache.Loader("windowgauge_test_policy_min", nil).(*windowGaugeVec)))
+	avg := &dto.Metric{}
+	assert.Nil(t, s.cache.Loader("avg_test_policy_avg", nil).(prometheus.Summary).Write(avg))
+	assert.Equal(t, float64(11), avg.GetSummary().GetSampleSum())
+	assert.Equal(t, uint64(4), avg.GetSummary().GetSampleCount())
+	assert.Empty(t, avg.GetSummary().GetQuantile())
+
+	labels := []*metrics.Dimension{{Name: "test_label", Value: "a"}}
+	for _, v := range []float64{3, 1, 5, 2} {
+		_ = s.Report(metrics.NewMultiDimensionMetricsX("test_policy_vec", labels, []*metrics.Metrics{
+			metrics.NewMetrics("max", v, metrics.PolicyMAX),
+			metrics.NewMetrics("avg", v, metrics.PolicyAVG),
+			metrics.NewMetrics("mid", v, metrics.PolicyMID),
+			metrics.NewMetrics("timer", float64(time.Millisecond), metrics.PolicyTimer),
+		}))
+	}
+	maxVec := s.cache.Loader("windowgaugevec_test_policy_vec_max", nil).(*windowGaugeVec)
+	assert.Equal(t, float64(5), testutil.ToFloat64(maxVec))
+	avgVec := s.cache.Loader("avgvec_test_policy_vec_avg", nil).(*prometheus.SummaryVec)
+	assert.Nil(t, avgVec.WithLabelValues("a").(prometheus.Summary).Write(avg))
+	assert.Equal(t, uint64(4), avg.GetSummary().GetSampleCount())
+}
+
+func TestSinkRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	s1 := NewSink(WithRegistry(registry))
+	s2 := NewSink()
+	assert.Equal(t, registry, s1.Gatherer())
+	assert.Equal(t, registry, s1.Registerer())
+	_ = s1.Report(metrics.NewSingleDimensionMetrics("test_registry", 1, metrics.PolicySUM))
+	_ = s2.Report(metrics.NewSingleDimensionMetrics("test_registry", 2, metrics.PolicySUM))
+
+	mfs, err := registry.Gather()
+	assert.Nil(t, err)
+	assert.Len(t, mfs, 1)
+	assert.Equal(t, float64(1), mfs[0].GetMetric()[0].GetCounter().GetValue())
+	mfs, err = s2.Gatherer().Gather()
+	assert.Nil(t, err)
+	assert.Len(t, mfs, 1)
+	assert.Equal(t, float64(2), mfs[0].GetMetric()[0].GetCounter().GetValue())
+
+	// sinks sharing a registry share the metrics.
+	s3 := NewSink(WithRegistry(registry))
+	_ = s3.Report(metrics.NewSingleDimensionMetrics("test_registry", 1, metrics.PolicySUM))
+	mfs, err = registry.Gather()
+	assert.Nil(t, err)
+	assert.Equal(t, float64(2), mfs[0].GetMetric()[0].GetCounter().GetValue())
+}
+
+func TestWindowGauge(t *testing.T) {
+	g := newWindowGaugeVec(prometheus.GaugeOpts{Name: "test_window_gauge"}, nil,
+		metrics.PolicyMAX, 50*time.Millisecond)
+	g.Observe(10)
+	g.Observe(5)
+	assert.Equal(t, float64(10), testutil.ToFloat64(g))
+	time.Sleep(60 * time.Millisecond)
+	g.Observe(5)
+	assert.Equal(t, float64(5), testutil.ToFloat64(g))
+	// the idle window is not exported.
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, 0, testutil.CollectAndCount(g))
+}
+
+func TestWindowGaugeInvalidValues(t *testing.T) {
+	g := newWindowGaugeVec(prometheus.GaugeOpts{Name: "test_window_gauge_invalid"}, []string{"a"},
+		metrics.PolicyMAX, time.Minute)
+	g.Observe(1, "\xff\xfe")
+	g.Observe(2, "b", "c")
+	g.Observe(3, "b")
+	assert.Equal(t, 1, testutil.CollectAndCount(g))
+}
+
 func TestMetrics(t *testing.T) {
 	setup(t)
 	s := Sink{
@@ -67,10 +172,12 @@ func TestMetrics(t *testing.T) {
 func TestRuntime(t *testing.T) {
 	setup(t)
 	cfg := &Config{Namespace: "test", Subsystem: "testing", RawMode: false, EnablePush: true, PushInterval: 1}
-	initSink(cfg)
-	GetDefaultPusher()
-	GetDefaultPrometheusSink()
-	//metrics.RegisterMetricsSink(s)
+	s, err := newConfigSink(sinkName, cfg)
+	assert.Nil(t, err)
+	defer s.Close()
+	metrics.RegisterMetricsSink(s)
+	l := startPusher(cfg, s)
+	defer l.close()
 	runtime.RuntimeMetrics()
 	t.Log(getMetrics(t))
 }
@@ -0,0 +1,150 @@
+package prometheus
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go"
+	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/metrics"
+	"trpc.group/trpc-go/trpc-go/server"
+)
+
+// Set the stream lifetime interval in milliseconds.
+// 10ms 100ms 1s 10s 1min 5min 30min 1h
+var streamLifetimeBounds = metrics.NewValueBounds(10.0, 100.0, 1000.0, 10000.0, 60000.0, 300000.0, 1800000.0, 3600000.0)
+
+// Set the interval of messages per stream.
+var streamMessageBounds = metrics.NewValueBounds(1.0, 2.0, 5.0, 10.0, 50.0, 100.0, 500.0, 1000.0, 10000.0)
+
+// streamStats statistics of a stream, reported once when the stream ends.
+type streamStats struct {
+	ctx      context.Context
+	prefix   string
+	labels   *filterLabels
+	msg      codec.Msg
+	begin    time.Time
+	sent     int64
+	received int64
+	once     sync.Once
+}
+
+// openStream reports the opened stream and returns its statistics.
+func openStream(ctx context.Context, prefix string, labels *filterLabels) *streamStats {
+	st := &streamStats{
+		ctx:    ctx,
+		prefix: prefix,
+		labels: labels,
+		msg:    trpc.Message(ctx),
+		begin:  time.Now(),
+	}
+	r := metrics.NewMultiDimensionMetricsX(prefix, labels.openDimensions(ctx, st.msg),
+		[]*metrics.Metrics{metrics.NewMetrics("opened", 1.0, metrics.PolicySUM)})
+	_ = GetDefaultPrometheusSink().Report(r)
+	return st
+}
+
+// close reports the lifetime, messages and final code of the stream, only the first call takes effect.
+func (st *streamStats) close(err error) {
+	st.once.Do(func() {
+		t := float64(time.Since(st.begin)) / float64(time.Millisecond)
+		ms := []*metrics.Metrics{
+			metrics.NewMetrics("closed", 1.0, metrics.PolicySUM),
+			metrics.NewMetrics("lifetime", t, metrics.PolicyHistogram),
+			metrics.NewMetrics("sent_messages", float64(atomic.LoadInt64(&st.sent)), metrics.PolicyHistogram),
+			metrics.NewMetrics("received_messages", float64(atomic.LoadInt64(&st.received)), metrics.PolicyHistogram),
+		}
+		metrics.Histogram(st.prefix+"_lifetime", streamLifetimeBounds)
+		metrics.Histogram(st.prefix+"_sent_messages", streamMessageBounds)
+		metrics.Histogram(st.prefix+"_received_messages", streamMessageBounds)
+		r := metrics.NewMultiDimensionMetricsX(st.prefix, st.labels.dimensions(st.ctx, st.msg, err), ms)
+		_ = GetDefaultPrometheusSink().Report(r, WithExemplar(exemplarFromContext(st.ctx)))
+	})
+}
+
+// StreamServerFilter server stream filter for prome.
+func StreamServerFilter(ss server.Stream, info *server.StreamServerInfo, handler server.StreamHandler) error {
+	st := openStream(ss.Context(), "ServerStream", serverLabels)
+	err := handler(&serverStream{Stream: ss, stats: st})
+	st.close(err)
+	return err
+}
+
+// serverStream counts the messages of the server stream.
+type serverStream struct {
+	server.Stream
+	stats *streamStats
+}
+
+// SendMsg sends the message and counts it.
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.Stream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.stats.sent, 1)
+	}
+	return err
+}
+
+// RecvMsg receives the message and counts it.
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.Stream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&s.stats.received, 1)
+	}
+	return err
+}
+
+// StreamClientFilter client stream filter for prome.
+// The stream is regarded as ended when RecvMsg returns an error or receives the only response of a
+// non-server-streaming RPC, streams which are abandoned before that are not reported as closed.
+func StreamClientFilter(ctx context.Context, desc *client.ClientStreamDesc,
+	streamer client.Streamer) (client.ClientStream, error) {
+	st := openStream(ctx, "ClientStream", clientLabels)
+	cs, err := streamer(ctx, desc)
+	if err != nil {
+		st.close(err)
+		return nil, err
+	}
+	return &clientStream{ClientStream: cs, desc: desc, stats: st}, nil
+}
+
+// clientStream counts the messages of the client stream and reports it when it ends.
+type clientStream struct {
+	client.ClientStream
+	desc  *client.ClientStreamDesc
+	stats *streamStats
+}
+
+// SendMsg sends the message and counts it.
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	switch {
+	case err == nil:
+		atomic.AddInt64(&s.stats.sent, 1)
+	case err != io.EOF:
+		// io.EOF means the stream has been closed by the server, whose status is returned by RecvMsg.
+		s.stats.close(err)
+	}
+	return err
+}
+
+// RecvMsg receives the message and counts it.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.stats.close(nil)
+	case err != nil:
+		s.stats.close(err)
+	default:
+		atomic.AddInt64(&s.stats.received, 1)
+		if !s.desc.ServerStreams {
+			s.stats.close(nil)
+		}
+	}
+	return err
+}
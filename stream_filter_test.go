@@ -0,0 +1,93 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"trpc.group/trpc-go/trpc-go/client"
+	"trpc.group/trpc-go/trpc-go/codec"
+	"trpc.group/trpc-go/trpc-go/server"
+)
+
+// testStream a stream which receives recv messages and then returns recvErr.
+type testStream struct {
+	ctx     context.Context
+	recv    int
+	recvErr error
+}
+
+func (s *testStream) Context() context.Context    { return s.ctx }
+func (s *testStream) SendMsg(m interface{}) error { return nil }
+func (s *testStream) CloseSend() error            { return nil }
+func (s *testStream) RecvMsg(m interface{}) error {
+	if s.recv == 0 {
+		return s.recvErr
+	}
+	s.recv--
+	return nil
+}
+
+func TestStreamServerFilter(t *testing.T) {
+	s, registry := useTestSink(t)
+	ctx, msg := codec.WithNewMessage(context.Background())
+	msg.WithCalleeMethod("Stream")
+
+	ss := &testStream{ctx: ctx, recv: 3, recvErr: io.EOF}
+	err := StreamServerFilter(ss, &server.StreamServerInfo{}, func(ss server.Stream) error {
+		for ss.RecvMsg(nil) == nil {
+		}
+		assert.Nil(t, ss.SendMsg(nil))
+		return errors.New("failed")
+	})
+	assert.NotNil(t, err)
+
+	opened, _ := s.cache.Get("countervec_ServerStream_opened")
+	assert.Equal(t, 1, testutil.CollectAndCount(opened.(prometheus.Collector)))
+	closed, _ := s.cache.Get("countervec_ServerStream_closed")
+	assert.Equal(t, 1.0, testutil.ToFloat64(closed.(*prometheus.CounterVec).WithLabelValues(
+		"", "", "", "Stream", "", "", "", "", "999")))
+	assert.Equal(t, 3.0, histogramSum(t, registry, "ServerStream_received_messages"))
+	assert.Equal(t, 1.0, histogramSum(t, registry, "ServerStream_sent_messages"))
+}
+
+func TestStreamClientFilter(t *testing.T) {
+	s, registry := useTestSink(t)
+	ctx, _ := codec.WithNewMessage(context.Background())
+
+	streamer := func(ctx context.Context, desc *client.ClientStreamDesc) (client.ClientStream, error) {
+		return &testStream{ctx: ctx, recv: 2, recvErr: io.EOF}, nil
+	}
+	cs, err := StreamClientFilter(ctx, &client.ClientStreamDesc{ServerStreams: true}, streamer)
+	assert.Nil(t, err)
+	assert.Nil(t, cs.SendMsg(nil))
+	for cs.RecvMsg(nil) == nil {
+	}
+	// the stream has been reported.
+	assert.Equal(t, io.EOF, cs.RecvMsg(nil))
+
+	// non-server-streaming rpc ends with the only response.
+	cs, err = StreamClientFilter(ctx, &client.ClientStreamDesc{}, streamer)
+	assert.Nil(t, err)
+	assert.Nil(t, cs.RecvMsg(nil))
+
+	_, err = StreamClientFilter(ctx, &client.ClientStreamDesc{},
+		func(ctx context.Context, desc *client.ClientStreamDesc) (client.ClientStream, error) {
+			return nil, errors.New("failed")
+		})
+	assert.NotNil(t, err)
+
+	closed, _ := s.cache.Get("countervec_ClientStream_closed")
+	assert.Equal(t, 3.0, testutil.ToFloat64(closed.(*prometheus.CounterVec).WithLabelValues(
+		"", "", "", "", "", "", "", "", "0"))+testutil.ToFloat64(closed.(*prometheus.CounterVec).WithLabelValues(
+		"", "", "", "", "", "", "", "", "999")))
+	opened, _ := s.cache.Get("countervec_ClientStream_opened")
+	assert.Equal(t, 3.0, testutil.ToFloat64(opened.(*prometheus.CounterVec).WithLabelValues(
+		"", "", "", "", "", "", "", "")))
+	assert.Equal(t, 3.0, histogramSum(t, registry, "ClientStream_received_messages"))
+	assert.Equal(t, 1.0, histogramSum(t, registry, "ClientStream_sent_messages"))
+}
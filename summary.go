@@ -0,0 +1,104 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultObjectives quantiles of summaries not configured by objectives.
+var defaultObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+// SummaryConfig records the histograms and timers whose names match the pattern as summaries,
+// it also configures the PolicyMID summaries.
+type SummaryConfig struct {
+	Match      string              `yaml:"match"`      //metric name or glob pattern.
+	Objectives map[float64]float64 `yaml:"objectives"` //quantile => absolute error, p50/p90/p99 by default.
+	MaxAge     uint32              `yaml:"maxage"`     //duration in seconds the observations are kept, 600s by default.
+	AgeBuckets uint32              `yaml:"agebuckets"` //number of buckets of the max age, 5 by default.
+}
+
+// summaryRule summary opts of the metrics whose names match the pattern.
+type summaryRule struct {
+	pattern string
+	opts    prometheus.SummaryOpts
+}
+
+// match reports whether the metric name matches the pattern.
+func (r summaryRule) match(name string) bool {
+	if r.pattern == name {
+		return true
+	}
+	ok, _ := path.Match(r.pattern, name)
+	return ok
+}
+
+// newSummaryRules converts the summary configs to summary rules.
+func newSummaryRules(cfgs []SummaryConfig) ([]summaryRule, error) {
+	rules := make([]summaryRule, 0, len(cfgs))
+	for _, c := range cfgs {
+		if err := checkSummary(c.Match, c.Objectives); err != nil {
+			return nil, err
+		}
+		rules = append(rules, summaryRule{
+			pattern: c.Match,
+			opts: prometheus.SummaryOpts{
+				Objectives: c.Objectives,
+				MaxAge:     time.Duration(c.MaxAge) * time.Second,
+				AgeBuckets: c.AgeBuckets,
+			},
+		})
+	}
+	return rules, nil
+}
+
+// checkSummary checks the match pattern and the objectives of the summary.
+func checkSummary(match string, objectives map[float64]float64) error {
+	if _, err := path.Match(match, ""); err != nil || match == "" {
+		return fmt.Errorf("summary %s: invalid match pattern", match)
+	}
+	for q, e := range objectives {
+		if q < 0 || q > 1 || e < 0 || e > 1 {
+			return fmt.Errorf("summary %s: %w", match, errors.New("invalid objective"))
+		}
+	}
+	return nil
+}
+
+// summaryRule returns the first summary rule matching the key.
+func (s *Sink) summaryRule(key string) (summaryRule, bool) {
+	for _, r := range s.summaryRules {
+		if r.match(key) {
+			return r, true
+		}
+	}
+	return summaryRule{}, false
+}
+
+// isSummary reports whether the histogram or timer should be recorded as a summary.
+func (s *Sink) isSummary(key string) bool {
+	_, ok := s.summaryRule(key)
+	return ok
+}
+
+// summaryOpts returns the opts of the summary.
+func (s *Sink) summaryOpts(key string) prometheus.SummaryOpts {
+	opts := prometheus.SummaryOpts{
+		Namespace:   s.ns,
+		Subsystem:   s.subsystem,
+		Name:        key,
+		ConstLabels: s.constLabels,
+		Objectives:  defaultObjectives,
+	}
+	if r, ok := s.summaryRule(key); ok {
+		if len(r.opts.Objectives) > 0 {
+			opts.Objectives = r.opts.Objectives
+		}
+		opts.MaxAge = r.opts.MaxAge
+		opts.AgeBuckets = r.opts.AgeBuckets
+	}
+	return opts
+}
@@ -0,0 +1,70 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+func TestSummaryConfig(t *testing.T) {
+	cfg := Config{}.Default()
+	assert.Nil(t, yaml.Unmarshal([]byte(`
+isolatedregistry: true
+summaries:
+  - match: test_summary_*
+    objectives: {0.5: 0.05, 0.99: 0.001}
+    maxage: 60
+    agebuckets: 3
+`), cfg))
+	s, err := newConfigSink(sinkName, cfg)
+	assert.Nil(t, err)
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_summary_histogram", 1, metrics.PolicyHistogram))
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_summary_timer", float64(time.Second), metrics.PolicyTimer))
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_mid", 1, metrics.PolicyMID))
+	_ = s.Report(metrics.NewSingleDimensionMetrics("test_histogram", 1, metrics.PolicyHistogram))
+	_ = s.Report(metrics.NewMultiDimensionMetricsX("test_summary", []*metrics.Dimension{{Name: "a", Value: "1"}},
+		[]*metrics.Metrics{metrics.NewMetrics("vec", 1, metrics.PolicyHistogram)}))
+
+	mfs, err := s.Gatherer().Gather()
+	assert.Nil(t, err)
+	types := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		types[mf.GetName()] = mf
+	}
+	histogram := types["Development_trpc_test_summary_histogram"]
+	assert.Equal(t, dto.MetricType_SUMMARY, histogram.GetType())
+	assert.Len(t, histogram.GetMetric()[0].GetSummary().GetQuantile(), 2)
+	timer := types["Development_trpc_test_summary_timer"]
+	assert.Equal(t, dto.MetricType_SUMMARY, timer.GetType())
+	assert.Equal(t, float64(1), timer.GetMetric()[0].GetSummary().GetSampleSum())
+	assert.Len(t, types["Development_trpc_test_mid"].GetMetric()[0].GetSummary().GetQuantile(), 3)
+	assert.Equal(t, dto.MetricType_HISTOGRAM, types["Development_trpc_test_histogram"].GetType())
+	assert.Equal(t, dto.MetricType_SUMMARY, types["Development_trpc_test_summary_vec"].GetType())
+}
+
+func TestSummaryConfigError(t *testing.T) {
+	_, err := newSummaryRules([]SummaryConfig{{Match: ""}})
+	assert.NotNil(t, err)
+	_, err = newSummaryRules([]SummaryConfig{{Match: "a", Objectives: map[float64]float64{2: 0.1}}})
+	assert.NotNil(t, err)
+}
+
+func TestWithSummary(t *testing.T) {
+	s := NewSink(WithSummary("test_*", prometheus.SummaryOpts{MaxAge: time.Minute}))
+	assert.True(t, s.isSummary("test_a"))
+	assert.False(t, s.isSummary("other"))
+	opts := s.summaryOpts("test_a")
+	assert.Equal(t, time.Minute, opts.MaxAge)
+	assert.Equal(t, defaultObjectives, opts.Objectives)
+}
+
+func TestWithSummaryInvalid(t *testing.T) {
+	s := NewSink(WithSummary("[", prometheus.SummaryOpts{}),
+		WithSummary("test_*", prometheus.SummaryOpts{Objectives: map[float64]float64{1.5: 0.1}}))
+	assert.Empty(t, s.summaryRules)
+}
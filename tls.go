@@ -0,0 +1,160 @@
+package prometheus
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"trpc.group/trpc-go/trpc-go/log"
+)
+
+// TLSConfig tls config of the exporter.
+type TLSConfig struct {
+	CertFile     string `yaml:"certfile"`     //server certificate file.
+	KeyFile      string `yaml:"keyfile"`      //server private key file.
+	ClientCAFile string `yaml:"clientcafile"` //CA file to verify client certificates, mTLS is enabled if set.
+}
+
+// AuthConfig access control config of the metrics path.
+type AuthConfig struct {
+	BasicAuth   string `yaml:"basicauth"`   //username and password split by a colon.
+	BearerToken string `yaml:"bearertoken"` //bearer token.
+}
+
+// enabled reports whether any authentication is configured.
+func (c AuthConfig) enabled() bool {
+	return c.BasicAuth != "" || c.BearerToken != ""
+}
+
+// check checks the basic auth has both username and password.
+func (c AuthConfig) check() error {
+	if c.BasicAuth == "" {
+		return nil
+	}
+	username, password := basicAuthForPasswordOption(c.BasicAuth)
+	if username == "" || password == "" {
+		return errors.New("basicauth should be username:password")
+	}
+	return nil
+}
+
+// authHandler rejects the requests which pass neither basic auth nor bearer token.
+func authHandler(cfg AuthConfig, next http.Handler) http.Handler {
+	username, password := basicAuthForPasswordOption(cfg.BasicAuth)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.BasicAuth != "" {
+			u, p, ok := r.BasicAuth()
+			if ok && secureEqual(u, username) && secureEqual(p, password) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if cfg.BearerToken != "" && secureEqual(r.Header.Get("Authorization"), "Bearer "+cfg.BearerToken) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cfg.BasicAuth != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// newTLSConfig creates the server tls config, it returns nil if tls is not configured.
+func newTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, errors.New("clientcafile requires certfile and keyfile")
+		}
+		return nil, nil
+	}
+	r := &tlsReloader{cfg: cfg}
+	// load once to surface config errors in Setup.
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config()
+		},
+	}, nil
+}
+
+// tlsReloader reloads certificates from disk when the files are modified.
+type tlsReloader struct {
+	cfg     TLSConfig
+	locker  sync.Mutex
+	modTime time.Time
+	current *tls.Config
+}
+
+// config returns the tls config of current files.
+func (r *tlsReloader) config() (*tls.Config, error) {
+	r.locker.Lock()
+	modTime := r.modTime
+	r.locker.Unlock()
+	if !latestModTime(r.cfg).Equal(modTime) {
+		// keep serving with the old certificates if reloading fails.
+		if err := r.reload(); err != nil {
+			log.Errorf("trpc-metrics-prometheus:reload certificates error:%v", err)
+		}
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	return r.current, nil
+}
+
+func (r *tlsReloader) reload() error {
+	modTime := latestModTime(r.cfg)
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	c := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.cfg.ClientCAFile != "" {
+		ca, err := ioutil.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return errors.New("no valid certificate in client ca file")
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.locker.Lock()
+	r.current = c
+	r.modTime = modTime
+	r.locker.Unlock()
+	return nil
+}
+
+// latestModTime returns the latest modification time of the configured files.
+func latestModTime(cfg TLSConfig) time.Time {
+	var latest time.Time
+	for _, f := range []string{cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,118 @@
+package prometheus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandler(t *testing.T) {
+	h := authHandler(AuthConfig{BasicAuth: "user:pass", BearerToken: "token"},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range []struct {
+		name   string
+		set    func(r *http.Request)
+		status int
+	}{
+		{"none", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("user", "pass") }, http.StatusOK},
+		{"wrong basic", func(r *http.Request) { r.SetBasicAuth("user", "wrong") }, http.StatusUnauthorized},
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		{"wrong bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			tt.set(r)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestAuthConfigError(t *testing.T) {
+	for _, auth := range []string{"secrettoken", ":pass", "user:"} {
+		cfg := Config{}.Default()
+		cfg.Auth.BasicAuth = auth
+		_, err := newMetricsServer(cfg, prometheus.NewRegistry(), prometheus.NewRegistry())
+		assert.NotNil(t, err, auth)
+	}
+
+	// passwords may contain colons.
+	h := authHandler(AuthConfig{BasicAuth: "user:pa:ss"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.SetBasicAuth("user", "pa:ss")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTLSExporter(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "first")
+
+	server, err := newMetricsServer(&Config{IP: "127.0.0.1", Path: "/metrics",
+		TLS: TLSConfig{CertFile: certFile, KeyFile: keyFile}}, prometheus.NewRegistry(), prometheus.NewRegistry())
+	assert.Nil(t, err)
+	addr, err := startMetricsServer(server, "/metrics")
+	assert.Nil(t, err)
+	defer server.Close()
+
+	assert.Equal(t, "first", peerCommonName(t, addr))
+
+	// rotate the certificate.
+	writeTestCert(t, certFile, keyFile, "second")
+	future := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(certFile, future, future))
+	assert.Equal(t, "second", peerCommonName(t, addr))
+}
+
+func TestTLSConfigError(t *testing.T) {
+	_, err := newTLSConfig(TLSConfig{ClientCAFile: "ca.pem"})
+	assert.NotNil(t, err)
+	_, err = newTLSConfig(TLSConfig{CertFile: "not_exist.pem", KeyFile: "not_exist.pem"})
+	assert.NotNil(t, err)
+	c, err := newTLSConfig(TLSConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, c)
+}
+
+func peerCommonName(t *testing.T, addr net.Addr) string {
+	conn, err := tls.Dial("tcp", addr.String(), &tls.Config{InsecureSkipVerify: true})
+	assert.Nil(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"trpc.group/trpc-go/trpc-go/log"
+	"trpc.group/trpc-go/trpc-go/metrics"
+)
+
+// defaultWindow default aggregation window of max/min gauges.
+const defaultWindow = time.Minute
+
+// windowGaugeVec exports the maximum or minimum value seen in the current window of each series as a gauge,
+// a window starts with the first value in it, and the series idle for a whole window is not exported.
+type windowGaugeVec struct {
+	desc   *prometheus.Desc
+	policy metrics.Policy
+	window time.Duration
+	locker sync.Mutex
+	series map[string]*windowSeries
+}
+
+// windowSeries the current window of a series.
+type windowSeries struct {
+	values []string
+	start  time.Time
+	value  float64
+}
+
+func newWindowGaugeVec(opts prometheus.GaugeOpts, labels []string, policy metrics.Policy,
+	window time.Duration) *windowGaugeVec {
+	if window <= 0 {
+		window = defaultWindow
+	}
+	return &windowGaugeVec{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			opts.Help, labels, opts.ConstLabels),
+		policy: policy,
+		window: window,
+		series: make(map[string]*windowSeries),
+	}
+}
+
+// Observe updates the extreme value of the current window of the series.
+func (w *windowGaugeVec) Observe(value float64, values ...string) {
+	now := time.Now()
+	key := seriesKey(values)
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	ws, ok := w.series[key]
+	switch {
+	case !ok || now.Sub(ws.start) >= w.window:
+		w.series[key] = &windowSeries{values: values, start: now, value: value}
+	case w.policy == metrics.PolicyMAX && value > ws.value:
+		ws.value = value
+	case w.policy == metrics.PolicyMIN && value < ws.value:
+		ws.value = value
+	}
+}
+
+// DeleteLabelValues deletes the series.
+func (w *windowGaugeVec) DeleteLabelValues(values ...string) bool {
+	key := seriesKey(values)
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	_, ok := w.series[key]
+	delete(w.series, key)
+	return ok
+}
+
+// Describe implements prometheus.Collector.
+func (w *windowGaugeVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- w.desc
+}
+
+// Collect implements prometheus.Collector, the series whose window has ended or whose label values are invalid
+// are dropped.
+func (w *windowGaugeVec) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	for key, ws := range w.series {
+		if now.Sub(ws.start) >= w.window {
+			delete(w.series, key)
+			continue
+		}
+		m, err := prometheus.NewConstMetric(w.desc, prometheus.GaugeValue, ws.value, ws.values...)
+		if err != nil {
+			log.Errorf("trpc-metrics-prometheus:window gauge dropped:%v", err)
+			delete(w.series, key)
+			continue
+		}
+		ch <- m
+	}
+}